@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/treeverse/lakefs/pkg/kv"
+	_ "github.com/treeverse/lakefs/pkg/kv/postgres"
+)
+
+// kvCmd represents the kv command
+var kvCmd = &cobra.Command{
+	Use:   "kv",
+	Short: "Manage the KV store",
+}
+
+var kvMigrateStoreCmd = &cobra.Command{
+	Use:   "migrate-store",
+	Short: "Copy the entries of the KV store to a store of another driver",
+	Long: `Copy the entries of the KV store to a store of another driver, i.e. to change the KV backend.
+Progress is printed with a resume token, pass it using --resume-token to continue a failed copy after the last
+copied entries. The source store is used as is, stop lakeFS while copying or verify the copy after writes stopped.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+		flags := cmd.Flags()
+		from, _ := flags.GetString("from")
+		fromDSN, _ := flags.GetString("from-dsn")
+		switch {
+		case from != "" && fromDSN == "":
+			fmt.Println("--from-dsn is required when using --from")
+			os.Exit(1)
+		case from == "" && fromDSN != "":
+			fmt.Println("--from is required when using --from-dsn")
+			os.Exit(1)
+		case from == "":
+			dbParams := loadConfig().GetDatabaseParams()
+			from, fromDSN = dbParams.Type, dbParams.ConnectionString
+		}
+		to, _ := flags.GetString("to")
+		toDSN, _ := flags.GetString("to-dsn")
+		prefix, _ := flags.GetString("prefix")
+		batchSize, _ := flags.GetInt("batch-size")
+		progressEvery, _ := flags.GetInt("progress-every")
+		verify, _ := flags.GetBool("verify")
+		resumeToken, _ := flags.GetString("resume-token")
+		var after []byte
+		if resumeToken != "" {
+			var err error
+			after, err = hex.DecodeString(resumeToken)
+			if err != nil {
+				fmt.Printf("Invalid resume token: %s\n", err)
+				os.Exit(1)
+			}
+		}
+
+		src, err := kv.Open(ctx, from, fromDSN)
+		if err != nil {
+			fmt.Printf("Failed to open source %s KV store: %s\n", from, err)
+			os.Exit(1)
+		}
+		defer src.Close()
+		dst, err := kv.Open(ctx, to, toDSN)
+		if err != nil {
+			fmt.Printf("Failed to open destination %s KV store: %s\n", to, err)
+			os.Exit(1)
+		}
+		defer dst.Close()
+
+		copied, lastKey, err := kv.Copy(ctx, dst, src, kv.CopyOptions{
+			Prefix:        []byte(prefix),
+			After:         after,
+			BatchSize:     batchSize,
+			ProgressEvery: progressEvery,
+			Progress: func(copied int64, lastKey []byte) {
+				fmt.Printf("Copied %d entries, resume token: %x\n", copied, lastKey)
+			},
+		})
+		if err != nil {
+			fmt.Printf("Failed to copy KV store after %d entries, resume token: %x: %s\n", copied, lastKey, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Copied %d entries from %s to %s\n", copied, from, to)
+		if !verify {
+			return
+		}
+
+		diff, err := kv.VerifyCopy(ctx, dst, src, []byte(prefix))
+		if err != nil {
+			fmt.Printf("Failed to verify KV store copy: %s\n", err)
+			os.Exit(1)
+		}
+		if !diff.Equal() {
+			fmt.Printf("Copy verification failed: %d missing, %d changed and %d extra entries\n", diff.Missing, diff.Changed, diff.Extra)
+			for _, key := range diff.Keys {
+				fmt.Printf("  %s\n", key)
+			}
+			os.Exit(1)
+		}
+		fmt.Println("Copy verified")
+	},
+}
+
+//nolint:gochecknoinits
+func init() {
+	rootCmd.AddCommand(kvCmd)
+	kvCmd.AddCommand(kvMigrateStoreCmd)
+	f := kvMigrateStoreCmd.Flags()
+	_ = f.String("from", "", "source KV driver, defaults to the configured database (requires --from-dsn)")
+	_ = f.String("from-dsn", "", "source KV connection string, required with --from")
+	_ = f.String("to", "", "destination KV driver")
+	_ = f.String("to-dsn", "", "destination KV connection string")
+	_ = f.String("prefix", "", "copy only the keys starting with prefix")
+	_ = f.String("resume-token", "", "resume a copy after the token printed by its progress")
+	_ = f.Int("batch-size", kv.DefaultCopyBatchSize, "number of entries set together on the destination")
+	_ = f.Int("progress-every", kv.DefaultCopyProgressEvery, "number of entries copied between progress reports")
+	_ = f.Bool("verify", true, "compare the stores after copying")
+	_ = kvMigrateStoreCmd.MarkFlagRequired("to")
+	_ = kvMigrateStoreCmd.MarkFlagRequired("to-dsn")
+}
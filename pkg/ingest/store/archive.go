@@ -0,0 +1,269 @@
+package store
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	ErrUnsupportedArchive   = errors.New("unsupported archive format")
+	ErrArchiveAfterNotFound = errors.New("after member not found in archive")
+)
+
+// ArchiveOpener opens the archive found at storageURI for reading
+type ArchiveOpener func(ctx context.Context, storageURI *url.URL) (io.ReadCloser, error)
+
+type archiveFormat int
+
+const (
+	archiveFormatTar archiveFormat = iota
+	archiveFormatTarGzip
+	archiveFormatZip
+)
+
+// archiveMember is a regular file member of an archive
+type archiveMember struct {
+	name  string
+	size  int64
+	mtime time.Time
+	open  func() (io.ReadCloser, error)
+}
+
+// archiveWalker walks the members of a tar (optionally gzip compressed) or zip archive as if they were objects.
+// The archive format is detected by the storage URI extension: .tar, .tar.gz, .tgz or .zip.
+// Members are walked in the archive order and not sorted by key. WalkOptions.After is the name of the last member
+// walked, the walk skips members up to and including it, and fails with ErrArchiveAfterNotFound when no member
+// matches it. Directories and members which are not regular files are skipped. Entries Address and
+// PhysicalAddress are the archive URI with the member name as its fragment.
+// The walker isn't registered by scheme, as archives are stored on any object store, it is built directly using
+// NewArchiveWalker.
+type archiveWalker struct {
+	open ArchiveOpener
+	mark Mark
+}
+
+func NewArchiveWalker(open ArchiveOpener) *archiveWalker {
+	return &archiveWalker{
+		open: open,
+		mark: Mark{HasMore: true},
+	}
+}
+
+func (a *archiveWalker) Walk(ctx context.Context, storageURI *url.URL, op WalkOptions, walkFn func(e ObjectStoreEntry) error) error {
+	startWalk(&a.mark)
+	err := a.walk(ctx, storageURI, op, walkFn)
+	return endWalk(ctx, &a.mark, err)
+}
+
+func (a *archiveWalker) walk(ctx context.Context, storageURI *url.URL, op WalkOptions, walkFn func(e ObjectStoreEntry) error) error {
+	format, err := getArchiveFormat(storageURI.Path)
+	if err != nil {
+		return err
+	}
+	r, err := a.open(ctx, storageURI)
+	if err != nil {
+		return fmt.Errorf("open archive %s: %w", storageURI, err)
+	}
+	defer func() { _ = r.Close() }()
+
+	var walkedBytes int64
+	passedAfter := op.After == ""
+	walkMember := func(m archiveMember) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !passedAfter {
+			passedAfter = m.name == op.After
+			return nil
+		}
+		if op.MaxBytes > 0 && walkedBytes >= op.MaxBytes {
+			// bytes budget reached - keep the current mark for resume
+			return errWalkLimitReached
+		}
+		address := getArchiveMemberURL(storageURI, m.name)
+		ent := ObjectStoreEntry{
+			FullKey:         m.name,
+			RelativeKey:     op.relativeKey(m.name, ""),
+			Address:         address,
+			PhysicalAddress: address,
+			Mtime:           m.mtime,
+			Size:            m.size,
+		}
+		if op.AddressStyle == AddressStyleKeyOnly {
+			ent.Address = m.name
+		}
+		if op.skip(ent) {
+			a.mark.Skipped++
+			return nil
+		}
+		if err := readArchiveMember(m, op, &ent); err != nil {
+			return fmt.Errorf("read archive member %s: %w", m.name, err)
+		}
+		a.mark.LastKey = m.name
+		a.mark.HasMore = true
+		if err := walkFn(ent); err != nil {
+			return err
+		}
+		walkedBytes += ent.Size
+		return nil
+	}
+
+	switch format {
+	case archiveFormatZip:
+		err = walkZip(ctx, r, walkMember)
+	case archiveFormatTarGzip:
+		var gz *gzip.Reader
+		gz, err = gzip.NewReader(r)
+		if err != nil {
+			return fmt.Errorf("read archive %s: %w", storageURI, err)
+		}
+		defer func() { _ = gz.Close() }()
+		err = walkTar(gz, walkMember)
+	default:
+		err = walkTar(r, walkMember)
+	}
+	if err != nil {
+		return err
+	}
+	if !passedAfter {
+		return fmt.Errorf("%w: %s", ErrArchiveAfterNotFound, op.After)
+	}
+	a.mark.LastKey = ""
+	a.mark.HasMore = false
+	return nil
+}
+
+func getArchiveFormat(p string) (archiveFormat, error) {
+	p = strings.ToLower(p)
+	switch {
+	case strings.HasSuffix(p, ".tar"):
+		return archiveFormatTar, nil
+	case strings.HasSuffix(p, ".tar.gz"), strings.HasSuffix(p, ".tgz"):
+		return archiveFormatTarGzip, nil
+	case strings.HasSuffix(p, ".zip"):
+		return archiveFormatZip, nil
+	default:
+		return 0, fmt.Errorf("%w: %s", ErrUnsupportedArchive, p)
+	}
+}
+
+func getArchiveMemberURL(storageURI *url.URL, name string) string {
+	u := *storageURI
+	u.Fragment = name
+	return u.String()
+}
+
+// readArchiveMember sets the entry checksums requested by op, reading the member content once
+func readArchiveMember(m archiveMember, op WalkOptions, ent *ObjectStoreEntry) error {
+	hashSHA256 := op.HashBelowBytes > 0 && m.size < op.HashBelowBytes
+	if !op.ComputeCRC32C && !hashSHA256 {
+		return nil
+	}
+	body, err := m.open()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = body.Close() }()
+	crc := crc32.New(crc32cTable)
+	sha := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(crc, sha), body); err != nil {
+		return err
+	}
+	if op.ComputeCRC32C {
+		ent.CRC32C = crc.Sum32()
+	}
+	if hashSHA256 {
+		ent.SHA256 = hex.EncodeToString(sha.Sum(nil))
+	}
+	return nil
+}
+
+func walkTar(r io.Reader, walkMember func(m archiveMember) error) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read tar: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		err = walkMember(archiveMember{
+			name:  hdr.Name,
+			size:  hdr.Size,
+			mtime: hdr.ModTime,
+			open:  func() (io.ReadCloser, error) { return io.NopCloser(tr), nil },
+		})
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// contextReader reads from Reader until ctx is done
+type contextReader struct {
+	ctx context.Context
+	io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.Reader.Read(p)
+}
+
+// walkZip walks the members of the zip read from r. Zip members are located using the central directory at the
+// end of the archive, so the archive is first copied to a temporary file.
+func walkZip(ctx context.Context, r io.Reader, walkMember func(m archiveMember) error) error {
+	f, err := os.CreateTemp("", "archive-walk-*.zip")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+	}()
+	size, err := io.Copy(f, &contextReader{ctx: ctx, Reader: r})
+	if err != nil {
+		return fmt.Errorf("copy zip: %w", err)
+	}
+	zr, err := zip.NewReader(f, size)
+	if err != nil {
+		return fmt.Errorf("read zip: %w", err)
+	}
+	for _, zf := range zr.File {
+		if !zf.Mode().IsRegular() {
+			continue
+		}
+		err := walkMember(archiveMember{
+			name:  zf.Name,
+			size:  int64(zf.UncompressedSize64),
+			mtime: zf.Modified,
+			open:  zf.Open,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *archiveWalker) Marker() Mark {
+	return a.mark
+}
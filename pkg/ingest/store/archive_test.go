@@ -0,0 +1,210 @@
+package store
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"hash/crc32"
+	"io"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+)
+
+var archiveTestMembers = []struct {
+	name    string
+	content string
+}{
+	{name: "data/b.csv", content: "b content"},
+	{name: "data/a.csv", content: "a"},
+	{name: "data/nested/c.csv", content: "c content"},
+}
+
+var archiveTestMtime = time.Date(2022, 3, 1, 10, 0, 0, 0, time.UTC)
+
+func newTestTar(t *testing.T, compress bool) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.Writer = &buf
+	var gz *gzip.Writer
+	if compress {
+		gz = gzip.NewWriter(&buf)
+		w = gz
+	}
+	tw := tar.NewWriter(w)
+	write := func(hdr *tar.Header, content string) {
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("write tar header %s: %s", hdr.Name, err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatalf("write tar member %s: %s", hdr.Name, err)
+		}
+	}
+	write(&tar.Header{Name: "data/", Typeflag: tar.TypeDir, Mode: 0755, ModTime: archiveTestMtime}, "")
+	for _, m := range archiveTestMembers {
+		write(&tar.Header{Name: m.name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(m.content)), ModTime: archiveTestMtime}, m.content)
+	}
+	write(&tar.Header{Name: "data/link", Typeflag: tar.TypeSymlink, Linkname: "a.csv", ModTime: archiveTestMtime}, "")
+	if err := tw.Close(); err != nil {
+		t.Fatalf("close tar: %s", err)
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			t.Fatalf("close gzip: %s", err)
+		}
+	}
+	return buf.Bytes()
+}
+
+func newTestZip(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	if _, err := zw.CreateHeader(&zip.FileHeader{Name: "data/", Modified: archiveTestMtime}); err != nil {
+		t.Fatalf("create zip dir: %s", err)
+	}
+	for _, m := range archiveTestMembers {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: m.name, Method: zip.Deflate, Modified: archiveTestMtime})
+		if err != nil {
+			t.Fatalf("create zip member %s: %s", m.name, err)
+		}
+		if _, err := w.Write([]byte(m.content)); err != nil {
+			t.Fatalf("write zip member %s: %s", m.name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("close zip: %s", err)
+	}
+	return buf.Bytes()
+}
+
+func crc32cOf(content string) uint32 {
+	return crc32.Checksum([]byte(content), crc32cTable)
+}
+
+func newTestArchiveWalker(archive []byte) *archiveWalker {
+	return NewArchiveWalker(func(_ context.Context, _ *url.URL) (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(archive)), nil
+	})
+}
+
+func walkArchive(t *testing.T, walker *archiveWalker, uri string, op WalkOptions) []ObjectStoreEntry {
+	t.Helper()
+	storageURI, err := url.Parse(uri)
+	if err != nil {
+		t.Fatalf("parse uri '%s': %s", uri, err)
+	}
+	var entries []ObjectStoreEntry
+	err = walker.Walk(context.Background(), storageURI, op, func(e ObjectStoreEntry) error {
+		entries = append(entries, e)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walk '%s': %s", uri, err)
+	}
+	return entries
+}
+
+func TestArchiveWalk(t *testing.T) {
+	tests := []struct {
+		name    string
+		uri     string
+		archive []byte
+	}{
+		{name: "tar", uri: "s3://bucket/dataset.tar", archive: newTestTar(t, false)},
+		{name: "tar_gzip", uri: "s3://bucket/dataset.tar.gz", archive: newTestTar(t, true)},
+		{name: "zip", uri: "s3://bucket/dataset.zip", archive: newTestZip(t)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			walker := newTestArchiveWalker(tt.archive)
+			entries := walkArchive(t, walker, tt.uri, WalkOptions{ComputeCRC32C: true})
+			if len(entries) != len(archiveTestMembers) {
+				t.Fatalf("walked %d entries, expected %d", len(entries), len(archiveTestMembers))
+			}
+			for i, e := range entries {
+				m := archiveTestMembers[i]
+				expected := ObjectStoreEntry{
+					FullKey:         m.name,
+					RelativeKey:     m.name,
+					Address:         tt.uri + "#" + m.name,
+					PhysicalAddress: tt.uri + "#" + m.name,
+					Mtime:           archiveTestMtime,
+					Size:            int64(len(m.content)),
+					CRC32C:          crc32cOf(m.content),
+				}
+				e.Mtime = e.Mtime.UTC()
+				if diff := deep.Equal(e, expected); diff != nil {
+					t.Errorf("entry %d diff: %s", i, diff)
+				}
+			}
+			if mark := walker.Marker(); mark.HasMore || mark.Reason != CompletionReasonCompleted {
+				t.Fatalf("mark %+v, expected completed walk", mark)
+			}
+		})
+	}
+}
+
+func TestArchiveWalkResume(t *testing.T) {
+	walker := newTestArchiveWalker(newTestTar(t, false))
+	entries := walkArchive(t, walker, "s3://bucket/dataset.tar", WalkOptions{MaxBytes: 1})
+	if keys := entriesKeys(entries); len(keys) != 1 || keys[0] != "data/b.csv" {
+		t.Fatalf("walked %s with MaxBytes, expected only the first member", keys)
+	}
+	mark := walker.Marker()
+	if !mark.HasMore || mark.LastKey != "data/b.csv" {
+		t.Fatalf("mark %+v, expected to have more after data/b.csv", mark)
+	}
+	entries = walkArchive(t, walker, "s3://bucket/dataset.tar", WalkOptions{After: mark.LastKey})
+	keys := entriesKeys(entries)
+	if diff := deep.Equal(keys, []string{"data/a.csv", "data/nested/c.csv"}); diff != nil {
+		t.Fatalf("resumed walk keys %s, diff: %s", keys, diff)
+	}
+
+	storageURI, _ := url.Parse("s3://bucket/dataset.tar")
+	err := walker.Walk(context.Background(), storageURI, WalkOptions{After: "data/missing.csv"}, func(e ObjectStoreEntry) error {
+		t.Errorf("walked %s after a missing member", e.FullKey)
+		return nil
+	})
+	if !errors.Is(err, ErrArchiveAfterNotFound) {
+		t.Fatalf("walk after a missing member err=%v, expected %s", err, ErrArchiveAfterNotFound)
+	}
+}
+
+func TestArchiveWalkZipCancel(t *testing.T) {
+	walker := newTestArchiveWalker(newTestZip(t))
+	storageURI, _ := url.Parse("s3://bucket/dataset.zip")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := walker.Walk(ctx, storageURI, WalkOptions{}, func(e ObjectStoreEntry) error {
+		t.Errorf("walked %s after cancel", e.FullKey)
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("walk err=%v, expected %s", err, context.Canceled)
+	}
+}
+
+func TestArchiveWalkHashBelowBytes(t *testing.T) {
+	walker := newTestArchiveWalker(newTestZip(t))
+	entries := walkArchive(t, walker, "s3://bucket/dataset.zip", WalkOptions{HashBelowBytes: 2})
+	for _, e := range entries {
+		if (e.SHA256 != "") != (e.FullKey == "data/a.csv") {
+			t.Errorf("entry %s (size %d) SHA256='%s', expected only entries under 2 bytes hashed", e.FullKey, e.Size, e.SHA256)
+		}
+	}
+}
+
+func TestArchiveWalkUnsupported(t *testing.T) {
+	walker := newTestArchiveWalker(newTestZip(t))
+	storageURI, _ := url.Parse("s3://bucket/dataset.rar")
+	err := walker.Walk(context.Background(), storageURI, WalkOptions{}, func(e ObjectStoreEntry) error { return nil })
+	if !errors.Is(err, ErrUnsupportedArchive) {
+		t.Fatalf("walk err=%v, expected %s", err, ErrUnsupportedArchive)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net/url"
 	"os"
 	"strings"
@@ -11,6 +12,7 @@ import (
 	"github.com/Azure/azure-pipeline-go/pipeline"
 	"github.com/Azure/azure-storage-blob-go/azblob"
 	"github.com/go-openapi/swag"
+	"github.com/treeverse/lakefs/pkg/block"
 )
 
 var (
@@ -40,9 +42,25 @@ func NewAzureBlobWalker(svc pipeline.Pipeline) (*azureBlobWalker, error) {
 	}, nil
 }
 
+// NewAzureBlobWalkerWithUserDelegation returns a walker that authorizes its requests using user delegation SAS,
+// signed by keys minted from provider. svc is used to send the signed requests and should not add credentials.
+func NewAzureBlobWalkerWithUserDelegation(svc pipeline.Pipeline, provider UserDelegationKeyProvider, opts AzureUserDelegationOptions) *azureBlobWalker {
+	return &azureBlobWalker{
+		client: svc,
+		mark:   Mark{HasMore: true},
+		signer: newUserDelegationSigner(provider, opts),
+	}
+}
+
 type azureBlobWalker struct {
 	client pipeline.Pipeline
 	mark   Mark
+	// signer, when set, signs requests and addresses with a user delegation SAS
+	signer *userDelegationSigner
+	// noHNS is set once the walked account is known to have no hierarchical namespace
+	noHNS bool
+	// throttle is the throttle of the current walk, if set
+	throttle *AdaptiveThrottle
 }
 
 // extractAzurePrefix takes a URL that looks like this: https://storageaccount.blob.core.windows.net/container/prefix
@@ -62,54 +80,363 @@ func extractAzurePrefix(storageURI *url.URL) (*url.URL, string, error) {
 	return storageURI.ResolveReference(&relativePath), parts[1], nil
 }
 
+// azurePhysicalAddress returns the PhysicalAddress of the blob named name in the container of containerURL
+func azurePhysicalAddress(containerURL *url.URL, name string) string {
+	return CanonicalAddress(block.StorageTypeAzure, containerURL.Host+containerURL.Path, name)
+}
+
 func getAzureBlobURL(containerURL *url.URL, blobName string) *url.URL {
 	relativePath := url.URL{Path: containerURL.Path + "/" + blobName}
 	return containerURL.ResolveReference(&relativePath)
 }
 
 func (a *azureBlobWalker) Walk(ctx context.Context, storageURI *url.URL, op WalkOptions, walkFn func(e ObjectStoreEntry) error) error {
+	startWalk(&a.mark)
+	err := a.walk(ctx, storageURI, op, walkFn)
+	return endWalk(ctx, &a.mark, err)
+}
+
+func (a *azureBlobWalker) walk(ctx context.Context, storageURI *url.URL, op WalkOptions, walkFn func(e ObjectStoreEntry) error) error {
 	// we use bucket as container and prefix as path
 	containerURL, prefix, err := extractAzurePrefix(storageURI)
 	if err != nil {
 		return err
 	}
-	container := azblob.NewContainerURL(*containerURL, a.client)
+	a.noHNS = false
+	a.throttle = op.Throttle
+	if op.ExactMatch {
+		return a.walkBlob(ctx, containerURL, prefix, op, walkFn)
+	}
 	notDone := true
+	var (
+		walkedBytes int64
+		restarts    int
+	)
+	if op.PageBoundaryMark {
+		// until a page is walked, resume from where this walk started
+		a.mark.ContinuationToken = op.ContinuationToken
+		a.mark.LastKey = op.After
+		a.mark.HasMore = true
+	}
 	for marker := (azblob.Marker{Val: &op.ContinuationToken}); notDone; {
-		listBlob, err := container.ListBlobsFlatSegment(ctx, marker,
-			azblob.ListBlobsSegmentOptions{Prefix: prefix})
-		if err != nil {
+		if err := op.Controller.wait(ctx); err != nil {
 			return err
 		}
-		a.mark.ContinuationToken = swag.StringValue(marker.Val)
-		marker = listBlob.NextMarker
-		for _, blobInfo := range listBlob.Segment.BlobItems {
-			// skipping everything in the page which is before 'After' (without forgetting the possible empty string key!)
-			if op.After != "" && blobInfo.Name <= op.After {
-				continue
-			}
-			a.mark.LastKey = blobInfo.Name
-			if err := walkFn(ObjectStoreEntry{
-				FullKey:     blobInfo.Name,
-				RelativeKey: strings.TrimPrefix(blobInfo.Name, prefix),
-				Address:     getAzureBlobURL(containerURL, blobInfo.Name).String(),
-				ETag:        string(blobInfo.Properties.Etag),
-				Mtime:       blobInfo.Properties.LastModified,
-				Size:        *blobInfo.Properties.ContentLength,
-			}); err != nil {
+		if op.PageBoundaryMark && op.MaxBytes > 0 && walkedBytes >= op.MaxBytes {
+			// bytes budget reached - the mark is at the end of the last walked page
+			return errWalkLimitReached
+		}
+		next, err := a.walkPage(ctx, containerURL, prefix, marker, op, &walkedBytes, walkFn)
+		if err != nil {
+			var listErr *azureListError
+			if restarts >= op.MaxListRestarts || swag.StringValue(marker.Val) == "" ||
+				!errors.As(err, &listErr) || !isAzureMarkerExpired(listErr.err) {
 				return err
 			}
+			// the continuation token expired - list again from the start of the prefix, re-listing all the pages
+			// already walked while the After filter skips their entries
+			restarts++
+			if a.mark.LastKey != "" {
+				op.After = a.mark.LastKey
+			}
+			marker = azblob.Marker{}
+			continue
 		}
+		marker = next
 		notDone = marker.NotDone()
 	}
 
 	a.mark = Mark{
 		HasMore: false,
+		Skipped: a.mark.Skipped,
 	}
 
 	return nil
 }
 
+// walkPage lists a single page of blobs starting at marker, passes its entries to walkFn and returns the next marker
+func (a *azureBlobWalker) walkPage(ctx context.Context, containerURL *url.URL, prefix string, marker azblob.Marker, op WalkOptions, walkedBytes *int64, walkFn func(e ObjectStoreEntry) error) (azblob.Marker, error) {
+	pageCtx := newPageContext(ctx, op.PageTimeout)
+	defer pageCtx.close()
+	// sign every page, the delegation key is refreshed before it expires on long walks
+	container, err := a.newContainerURL(ctx, containerURL)
+	if err != nil {
+		return marker, err
+	}
+	listBlob, err := container.ListBlobsFlatSegment(pageCtx, marker, azblob.ListBlobsSegmentOptions{
+		Prefix:  prefix,
+		Details: azblob.BlobListingDetails{Copy: op.IncludeCopySource},
+	})
+	if err != nil {
+		return marker, &azureListError{err: pageCtx.wrapErr(err)}
+	}
+	if !op.PageBoundaryMark {
+		a.mark.ContinuationToken = swag.StringValue(marker.Val)
+	}
+	marker = listBlob.NextMarker
+	page := newListedPage(len(listBlob.Segment.BlobItems))
+	for _, blobInfo := range listBlob.Segment.BlobItems {
+		// skipping everything in the page which is before 'After' (without forgetting the possible empty string key!)
+		if op.After != "" && blobInfo.Name <= op.After {
+			continue
+		}
+		if !matchBlobType(string(blobInfo.Properties.BlobType), op) {
+			page.skip()
+			continue
+		}
+		ent, err := a.listedEntry(ctx, containerURL, prefix, blobInfo, op)
+		if err != nil {
+			return marker, err
+		}
+		if !matchEncryption(ent, op) || op.skip(ent) {
+			page.skip()
+			continue
+		}
+		page.add(ent)
+	}
+	if err := a.walkEntries(pageCtx, container, page, op, walkedBytes, walkFn); err != nil {
+		return marker, err
+	}
+	if op.PageBoundaryMark {
+		if items := listBlob.Segment.BlobItems; len(items) > 0 {
+			a.mark.LastKey = items[len(items)-1].Name
+		}
+		a.mark.ContinuationToken = swag.StringValue(marker.Val)
+		a.mark.HasMore = marker.NotDone()
+	}
+	return marker, nil
+}
+
+// listedEntry returns the entry of a listed blob, with the fields set from the listing
+func (a *azureBlobWalker) listedEntry(ctx context.Context, containerURL *url.URL, prefix string, blobInfo azblob.BlobItemInternal, op WalkOptions) (ObjectStoreEntry, error) {
+	address, err := a.blobAddress(ctx, containerURL, blobInfo.Name, op)
+	if err != nil {
+		return ObjectStoreEntry{}, err
+	}
+	ent := ObjectStoreEntry{
+		FullKey:         blobInfo.Name,
+		RelativeKey:     op.relativeKey(blobInfo.Name, prefix),
+		Address:         address,
+		PhysicalAddress: azurePhysicalAddress(containerURL, blobInfo.Name),
+		ETag:            normalizeETag(string(blobInfo.Properties.Etag)),
+		Mtime:           blobInfo.Properties.LastModified,
+		Size:            *blobInfo.Properties.ContentLength,
+		BlobType:        string(blobInfo.Properties.BlobType),
+	}
+	if op.IncludeRawETag {
+		ent.RawETag = string(blobInfo.Properties.Etag)
+	}
+	if op.IncludeCopySource {
+		ent.CopySource = swag.StringValue(blobInfo.Properties.CopySource)
+	}
+	ent.EncryptionScope = swag.StringValue(blobInfo.Properties.EncryptionScope)
+	ent.CustomerProvidedKey = swag.StringValue(blobInfo.Properties.CustomerProvidedKeySha256) != ""
+	return ent, nil
+}
+
+// matchBlobType reports if blobType is walked by the op BlobTypes filter
+func matchBlobType(blobType string, op WalkOptions) bool {
+	return len(op.BlobTypes) == 0 || swag.ContainsStrings(op.BlobTypes, blobType)
+}
+
+// walkBlob walks the single blob named name, if it exists, reading its properties instead of listing
+func (a *azureBlobWalker) walkBlob(ctx context.Context, containerURL *url.URL, name string, op WalkOptions, walkFn func(e ObjectStoreEntry) error) error {
+	if name == "" {
+		return fmt.Errorf("%w: exact match requires a blob name: %s", ErrAzureInvalidURL, containerURL)
+	}
+	pageCtx := newPageContext(ctx, op.PageTimeout)
+	defer pageCtx.close()
+	container, err := a.newContainerURL(ctx, containerURL)
+	if err != nil {
+		return err
+	}
+	page := newListedPage(1)
+	if op.After == "" || name > op.After {
+		props, err := container.NewBlobURL(name).GetProperties(pageCtx, azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
+		var storageErr azblob.StorageError
+		if errors.As(err, &storageErr) && storageErr.ServiceCode() == azblob.ServiceCodeBlobNotFound {
+			props, err = nil, nil
+		}
+		if err != nil {
+			return pageCtx.wrapErr(err)
+		}
+		if props != nil && !matchBlobType(string(props.BlobType()), op) {
+			page.skip()
+			props = nil
+		}
+		if props != nil {
+			address, err := a.blobAddress(ctx, containerURL, name, op)
+			if err != nil {
+				return err
+			}
+			var basePath string
+			if idx := strings.LastIndex(name, "/"); idx != -1 {
+				basePath = name[:idx+1]
+			}
+			ent := ObjectStoreEntry{
+				FullKey:         name,
+				RelativeKey:     op.relativeKey(name, basePath),
+				Address:         address,
+				PhysicalAddress: azurePhysicalAddress(containerURL, name),
+				ETag:            normalizeETag(string(props.ETag())),
+				Mtime:           props.LastModified(),
+				Size:            props.ContentLength(),
+				BlobType:        string(props.BlobType()),
+			}
+			if op.IncludeRawETag {
+				ent.RawETag = string(props.ETag())
+			}
+			if op.IncludeCopySource {
+				ent.CopySource = props.CopySource()
+			}
+			ent.EncryptionScope = props.EncryptionScope()
+			ent.CustomerProvidedKey = props.EncryptionKeySha256() != ""
+			if !matchEncryption(ent, op) || op.skip(ent) {
+				page.skip()
+			} else {
+				page.add(ent)
+			}
+		}
+	}
+	var walkedBytes int64
+	if err := a.walkEntries(pageCtx, container, page, op, &walkedBytes, walkFn); err != nil {
+		return err
+	}
+	a.mark = Mark{
+		HasMore: false,
+		Skipped: a.mark.Skipped,
+	}
+	return nil
+}
+
+// walkEntries fetches the additional metadata requested by op for the page entries and passes them to walkFn
+func (a *azureBlobWalker) walkEntries(pageCtx *pageContext, container azblob.ContainerURL, page *listedPage, op WalkOptions, walkedBytes *int64, walkFn func(e ObjectStoreEntry) error) error {
+	if op.IncludeReplicationStatus || op.SkipIncompleteReplication {
+		if err := getEntriesReplicationStatus(pageCtx, container, page.entries, op.Concurrency); err != nil {
+			return pageCtx.wrapErr(err)
+		}
+	}
+	if op.SkipIncompleteReplication {
+		page.filter(replicationComplete)
+	}
+	// fetch access control and read the content only of the entries walked within the bytes budget
+	maxBytes := op.MaxBytes
+	if op.PageBoundaryMark {
+		// the budget is checked between pages
+		maxBytes = 0
+	}
+	limited := page.withinBudget(maxBytes, *walkedBytes)
+	entries := page.entries
+	if op.IncludeAccessControl && !a.noHNS {
+		hns, err := a.getEntriesAccessControl(pageCtx, container, entries, op.Concurrency)
+		if err != nil {
+			return pageCtx.wrapErr(err)
+		}
+		a.noHNS = !hns
+	}
+	if op.ComputeCRC32C {
+		if err := computeCRC32C(pageCtx, entries, op.Concurrency, openAzureBlob(container)); err != nil {
+			return pageCtx.wrapErr(err)
+		}
+	}
+	if op.HashBelowBytes > 0 {
+		if err := computeSHA256(pageCtx, entries, op.HashBelowBytes, op.Concurrency, openAzureBlob(container)); err != nil {
+			return pageCtx.wrapErr(err)
+		}
+	}
+	for i, ent := range entries {
+		page.countSkipped(i, &a.mark)
+		if err := pageCtx.Err(); err != nil {
+			return pageCtx.wrapErr(err)
+		}
+		if !op.PageBoundaryMark {
+			a.mark.LastKey = ent.FullKey
+		}
+		if err := walkFn(ent); err != nil {
+			return err
+		}
+		*walkedBytes += ent.Size
+	}
+	page.countSkipped(len(entries), &a.mark)
+	if limited {
+		// bytes budget reached - keep the current mark for resume
+		a.mark.HasMore = true
+		return errWalkLimitReached
+	}
+	return nil
+}
+
+// newContainerURL returns the container URL used to call the service, signed when the walker signs requests
+func (a *azureBlobWalker) newContainerURL(ctx context.Context, containerURL *url.URL) (azblob.ContainerURL, error) {
+	if a.signer == nil {
+		return azblob.NewContainerURL(*containerURL, a.pipeline()), nil
+	}
+	signedURL, err := a.signer.signContainerURL(ctx, containerURL)
+	if err != nil {
+		return azblob.ContainerURL{}, err
+	}
+	return azblob.NewContainerURL(*signedURL, a.pipeline()), nil
+}
+
+// blobAddress returns the Address of the walked blob name, based on the walk AddressStyle
+func (a *azureBlobWalker) blobAddress(ctx context.Context, containerURL *url.URL, name string, op WalkOptions) (string, error) {
+	switch {
+	case op.AddressStyle == AddressStyleKeyOnly:
+		return name, nil
+	case a.signer != nil && a.signer.opts.PresignAddress:
+		return a.signer.signBlobAddress(ctx, containerURL, name)
+	default:
+		return getAzureBlobURL(containerURL, name).String(), nil
+	}
+}
+
+// matchEncryption reports if the entry encryption matches the encryption required by op
+func matchEncryption(ent ObjectStoreEntry, op WalkOptions) bool {
+	if len(op.EncryptionScopes) > 0 && !swag.ContainsStrings(op.EncryptionScopes, ent.EncryptionScope) {
+		return false
+	}
+	return !op.RequireCustomerProvidedKey || ent.CustomerProvidedKey
+}
+
+// replicationComplete reports if ent has no replication rules or its replication completed
+func replicationComplete(ent ObjectStoreEntry) bool {
+	return ent.ReplicationStatus == "" || ent.ReplicationStatus == ReplicationStatusComplete
+}
+
+// azureListError is a failure of the call listing a page, the only call failing on an expired continuation marker
+type azureListError struct {
+	err error
+}
+
+func (e *azureListError) Error() string {
+	return e.err.Error()
+}
+
+func (e *azureListError) Unwrap() error {
+	return e.err
+}
+
+// isAzureMarkerExpired reports if err is the service rejecting a list continuation marker, which happens once the
+// marker expires on long walks
+func isAzureMarkerExpired(err error) bool {
+	var storageErr azblob.StorageError
+	if !errors.As(err, &storageErr) {
+		return false
+	}
+	code := storageErr.ServiceCode()
+	return code == azblob.ServiceCodeInvalidQueryParameterValue || code == azblob.ServiceCodeOutOfRangeInput
+}
+
+func openAzureBlob(container azblob.ContainerURL) objectOpener {
+	return func(ctx context.Context, e ObjectStoreEntry) (io.ReadCloser, error) {
+		resp, err := container.NewBlobURL(e.FullKey).Download(ctx, 0, azblob.CountToEnd, azblob.BlobAccessConditions{}, false, azblob.ClientProvidedKeyOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("download blob %s: %w", e.Address, err)
+		}
+		return resp.Body(azblob.RetryReaderOptions{}), nil
+	}
+}
+
 func (a *azureBlobWalker) Marker() Mark {
 	return a.mark
 }
@@ -0,0 +1,87 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+// azureNonHNSServiceCodes are returned by the Data Lake endpoint for accounts without hierarchical namespace
+var azureNonHNSServiceCodes = []azblob.ServiceCodeType{
+	"HierarchicalNamespaceNotEnabled",
+	"EndpointUnsupportedAccountFeatures",
+}
+
+// accessControlResponder fails responses of the get access control request that aren't successful
+var accessControlResponder = pipeline.FactoryFunc(func(next pipeline.Policy, _ *pipeline.PolicyOptions) pipeline.PolicyFunc {
+	return func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+		resp, err := next.Do(ctx, request)
+		if err != nil {
+			return resp, err
+		}
+		if resp.Response().StatusCode != http.StatusOK {
+			return resp, azblob.NewResponseError(nil, resp.Response(), "get access control")
+		}
+		return resp, nil
+	}
+})
+
+// getAccessControl sets the entry owner, group, permissions and ACL using the Data Lake (dfs) endpoint of the
+// container. Returns false when the account has no hierarchical namespace, leaving the entry unchanged.
+func (a *azureBlobWalker) getAccessControl(ctx context.Context, container azblob.ContainerURL, e *ObjectStoreEntry) (bool, error) {
+	containerURL := container.URL()
+	u := getAzureBlobURL(&containerURL, e.FullKey)
+	u.Host = strings.Replace(u.Host, ".blob.", ".dfs.", 1)
+	// keep the container SAS, if signed
+	query := containerURL.Query()
+	query.Set("action", "getAccessControl")
+	u.RawQuery = query.Encode()
+	req, err := pipeline.NewRequest(http.MethodHead, *u, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("x-ms-version", azblob.ServiceVersion)
+	resp, err := a.pipeline().Do(ctx, accessControlResponder, req)
+	if resp != nil && resp.Response() != nil && resp.Response().Body != nil {
+		_ = resp.Response().Body.Close()
+	}
+	var storageErr azblob.StorageError
+	if errors.As(err, &storageErr) {
+		for _, code := range azureNonHNSServiceCodes {
+			if storageErr.ServiceCode() == code {
+				return false, nil
+			}
+		}
+	}
+	if err != nil {
+		return false, err
+	}
+	header := resp.Response().Header
+	e.Owner = header.Get("x-ms-owner")
+	e.Group = header.Get("x-ms-group")
+	e.Permissions = header.Get("x-ms-permissions")
+	e.ACL = header.Get("x-ms-acl")
+	return true, nil
+}
+
+// getEntriesAccessControl sets the access control of the entries, stops on the first entry found to be in an
+// account without hierarchical namespace
+func (a *azureBlobWalker) getEntriesAccessControl(ctx context.Context, container azblob.ContainerURL, entries []ObjectStoreEntry, concurrency int) (bool, error) {
+	if len(entries) == 0 {
+		return true, nil
+	}
+	// the first entry tells if the account has hierarchical namespace
+	hns, err := a.getAccessControl(ctx, container, &entries[0])
+	if err != nil || !hns {
+		return hns, err
+	}
+	err = forEachEntry(ctx, entries[1:], concurrency, func(ctx context.Context, e *ObjectStoreEntry) error {
+		_, err := a.getAccessControl(ctx, container, e)
+		return err
+	})
+	return true, err
+}
@@ -0,0 +1,172 @@
+package store
+
+import (
+	"context"
+	"math"
+	"net/url"
+	"strings"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+const (
+	// DefaultEstimatePages is the number of pages Estimate lists when WalkOptions.EstimatePages is not set
+	DefaultEstimatePages = 10
+
+	// maxEstimatePositions bounds the key positions used to place a key in the key space
+	maxEstimatePositions = 12
+)
+
+// EstimateResult is the estimated size of a walk, extrapolated from a sample of its listing
+type EstimateResult struct {
+	// Objects is the estimated number of walked objects
+	Objects int64
+	// Bytes is the estimated total size of the walked objects
+	Bytes int64
+	// SamplePages is the number of pages listed for the estimate
+	SamplePages int
+	// SampleObjects is the number of objects listed for the estimate, including objects the walk skips
+	SampleObjects int64
+	// Exact is set when the sample listed all the objects, Objects and Bytes are then the walk totals
+	Exact bool
+}
+
+// Estimate estimates the number and total size of the objects walked under storageURI by listing up to
+// op.EstimatePages pages, without walking the rest of the listing. The blob type, encryption and Skip filters
+// of op are applied to the sample, the content isn't read.
+// The sampled pages are the first pages of the listing. Their share of the listing is extrapolated by placing
+// their keys in the key space spanned by the characters varying between the sampled keys, so the estimate
+// assumes the keys are spread evenly over that space, i.e. random or hashed names. It is off for skewed keys,
+// such as sequential numbers padded with zeros beyond the largest one, or names the sample has no characters of.
+func (a *azureBlobWalker) Estimate(ctx context.Context, storageURI *url.URL, op WalkOptions) (EstimateResult, error) {
+	containerURL, prefix, err := extractAzurePrefix(storageURI)
+	if err != nil {
+		return EstimateResult{}, err
+	}
+	a.throttle = op.Throttle
+	pages := op.EstimatePages
+	if pages <= 0 {
+		pages = DefaultEstimatePages
+	}
+	var (
+		result EstimateResult
+		keys   []string
+	)
+	for marker := (azblob.Marker{}); result.SamplePages < pages; {
+		if err := op.Controller.wait(ctx); err != nil {
+			return EstimateResult{}, err
+		}
+		listBlob, err := a.listEstimatePage(ctx, containerURL, prefix, marker, op)
+		if err != nil {
+			return EstimateResult{}, err
+		}
+		result.SamplePages++
+		for _, blobInfo := range listBlob.Segment.BlobItems {
+			keys = append(keys, blobInfo.Name)
+			if !matchBlobType(string(blobInfo.Properties.BlobType), op) {
+				continue
+			}
+			ent, err := a.listedEntry(ctx, containerURL, prefix, blobInfo, op)
+			if err != nil {
+				return EstimateResult{}, err
+			}
+			if !matchEncryption(ent, op) || op.skip(ent) {
+				continue
+			}
+			result.Objects++
+			result.Bytes += ent.Size
+		}
+		marker = listBlob.NextMarker
+		if !marker.NotDone() {
+			result.Exact = true
+			break
+		}
+	}
+	result.SampleObjects = int64(len(keys))
+	if result.Exact || len(keys) == 0 {
+		return result, nil
+	}
+	scale := extrapolateListing(prefix, keys) / float64(len(keys))
+	result.Objects = int64(math.Round(float64(result.Objects) * scale))
+	result.Bytes = int64(math.Round(float64(result.Bytes) * scale))
+	return result, nil
+}
+
+// listEstimatePage lists a single page of blobs starting at marker
+func (a *azureBlobWalker) listEstimatePage(ctx context.Context, containerURL *url.URL, prefix string, marker azblob.Marker, op WalkOptions) (*azblob.ListBlobsFlatSegmentResponse, error) {
+	pageCtx := newPageContext(ctx, op.PageTimeout)
+	defer pageCtx.close()
+	container, err := a.newContainerURL(ctx, containerURL)
+	if err != nil {
+		return nil, err
+	}
+	listBlob, err := container.ListBlobsFlatSegment(pageCtx, marker, azblob.ListBlobsSegmentOptions{Prefix: prefix})
+	if err != nil {
+		return nil, pageCtx.wrapErr(err)
+	}
+	return listBlob, nil
+}
+
+// extrapolateListing estimates the number of keys listed under prefix from keys, the sorted keys of the first
+// pages of the listing. The keys are placed in the key space spanned by the characters varying between them, the
+// sample size is extrapolated by the share of the key space following the first key that the sample covers.
+func extrapolateListing(prefix string, keys []string) float64 {
+	n := float64(len(keys))
+	if len(keys) < 2 {
+		return n
+	}
+	first := strings.TrimPrefix(keys[0], prefix)
+	last := strings.TrimPrefix(keys[len(keys)-1], prefix)
+	common := 0
+	for common < len(first) && common < len(last) && first[common] == last[common] {
+		common++
+	}
+	// the alphabet are the characters found after the prefix shared by all the sampled keys
+	var inAlphabet [math.MaxUint8 + 1]bool
+	maxLen := 0
+	for _, key := range keys {
+		suffix := strings.TrimPrefix(key, prefix)
+		for i := common; i < len(suffix); i++ {
+			inAlphabet[suffix[i]] = true
+		}
+		if len(suffix) > maxLen {
+			maxLen = len(suffix)
+		}
+	}
+	var rank [math.MaxUint8 + 1]int
+	size := 0
+	for c, ok := range inAlphabet {
+		if ok {
+			rank[c] = size
+			size++
+		}
+	}
+	if size < 2 {
+		return n
+	}
+	// characters of the shared prefix found in the alphabet may vary past the sample
+	positions := make([]int, 0, maxEstimatePositions)
+	for i := 0; i < maxLen && len(positions) < maxEstimatePositions; i++ {
+		if i >= common || inAlphabet[first[i]] {
+			positions = append(positions, i)
+		}
+	}
+	place := func(key string) float64 {
+		var p float64
+		unit := 1.0
+		for _, i := range positions {
+			unit /= float64(size)
+			if i < len(key) {
+				p += float64(rank[key[i]]) * unit
+			}
+		}
+		return p
+	}
+	start := place(first)
+	covered := (place(last) - start) / (1 - start)
+	if covered <= 0 {
+		return n
+	}
+	// the sample spans the key space between its first and last keys
+	return (n-1)/covered + 1
+}
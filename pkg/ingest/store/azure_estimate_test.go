@@ -0,0 +1,80 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestAzureEstimate(t *testing.T) {
+	const blobSize = 10
+	content := make([]byte, blobSize)
+	random := rand.New(rand.NewSource(1))
+	const letters = "abcdefghijklmnopqrstuvwxyz"
+	randomName := func() string {
+		name := make([]byte, 6)
+		for i := range name {
+			name[i] = letters[random.Intn(len(letters))]
+		}
+		return "data/" + string(name) + ".parquet"
+	}
+	tests := []struct {
+		name  string
+		names func(i int) string
+		count int
+	}{
+		{name: "hex", names: func(i int) string { return fmt.Sprintf("data/%03x", i) }, count: 4096},
+		{name: "random", names: func(int) string { return randomName() }, count: 5000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blobs := make(map[string]bool)
+			var container []fakeAzureBlob
+			for i := 0; len(blobs) < tt.count; i++ {
+				name := tt.names(i)
+				if !blobs[name] {
+					blobs[name] = true
+					container = append(container, fakeAzureBlob{Name: name, Content: content})
+				}
+			}
+			fake := newFakeAzureContainer(100, container...)
+			walker, storageURI := newFakeAzureWalker(t, fake)
+			storageURI.Path += "data/"
+
+			result, err := walker.Estimate(context.Background(), storageURI, WalkOptions{EstimatePages: 3})
+			if err != nil {
+				t.Fatalf("estimate: %s", err)
+			}
+			if result.Exact || result.SamplePages != 3 || result.SampleObjects != 300 || fake.listed != 3 {
+				t.Fatalf("estimate %+v after %d list calls, expected a sample of 3 pages", result, fake.listed)
+			}
+			// within a fifth of the actual totals
+			if diff := math.Abs(float64(result.Objects-int64(tt.count))) / float64(tt.count); diff > 0.2 {
+				t.Fatalf("estimated %d objects, expected about %d", result.Objects, tt.count)
+			}
+			if diff := math.Abs(float64(result.Bytes - result.Objects*blobSize)); diff > blobSize {
+				t.Fatalf("estimated %d bytes for %d objects, expected about %d bytes", result.Bytes, result.Objects, result.Objects*blobSize)
+			}
+		})
+	}
+}
+
+func TestAzureEstimateExact(t *testing.T) {
+	fake := newFakeAzureContainer(2,
+		fakeAzureBlob{Name: "a", Content: []byte("1")},
+		fakeAzureBlob{Name: "b.tmp", Content: []byte("22")},
+		fakeAzureBlob{Name: "c", Content: []byte("333")},
+	)
+	walker, storageURI := newFakeAzureWalker(t, fake)
+	op := WalkOptions{Skip: func(entry ObjectStoreEntry) bool { return entry.FullKey == "b.tmp" }}
+	result, err := walker.Estimate(context.Background(), storageURI, op)
+	if err != nil {
+		t.Fatalf("estimate: %s", err)
+	}
+	expected := EstimateResult{Objects: 2, Bytes: 4, SamplePages: 2, SampleObjects: 3, Exact: true}
+	if result != expected {
+		t.Fatalf("estimate %+v, expected the walk totals %+v", result, expected)
+	}
+}
@@ -0,0 +1,51 @@
+package store
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+const (
+	// ReplicationStatusComplete is the ObjectStoreEntry.ReplicationStatus of an object replicated by all its rules
+	ReplicationStatusComplete = "complete"
+
+	// azureReplicationHeaderPrefix prefixes the object replication status headers of a source blob, one header
+	// per replication rule: x-ms-or-<policy id>_<rule id>
+	azureReplicationHeaderPrefix = "X-Ms-Or-"
+	// azureReplicationPolicyHeader is set on destination blobs, it holds a policy id and not a status
+	azureReplicationPolicyHeader = "X-Ms-Or-Policy-Id"
+)
+
+// replicationStatus returns the status of the blob replication rules found in header, the first status that is
+// not complete when there is one. Returns an empty status when the blob has no replication rules.
+func replicationStatus(header http.Header) string {
+	status := ""
+	for name, values := range header {
+		name = http.CanonicalHeaderKey(name)
+		if !strings.HasPrefix(name, azureReplicationHeaderPrefix) || name == azureReplicationPolicyHeader || len(values) == 0 {
+			continue
+		}
+		ruleStatus := strings.ToLower(values[0])
+		if ruleStatus != ReplicationStatusComplete {
+			return ruleStatus
+		}
+		status = ruleStatus
+	}
+	return status
+}
+
+// getEntriesReplicationStatus sets the replication status of the entries, reading the properties of each blob
+// as the listing doesn't include them
+func getEntriesReplicationStatus(ctx context.Context, container azblob.ContainerURL, entries []ObjectStoreEntry, concurrency int) error {
+	return forEachEntry(ctx, entries, concurrency, func(ctx context.Context, e *ObjectStoreEntry) error {
+		resp, err := container.NewBlobURL(e.FullKey).GetProperties(ctx, azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
+		if err != nil {
+			return err
+		}
+		e.ReplicationStatus = replicationStatus(resp.Response().Header)
+		return nil
+	})
+}
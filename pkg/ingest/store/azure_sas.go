@@ -0,0 +1,125 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+const (
+	defaultUserDelegationKeyTTL     = time.Hour
+	defaultUserDelegationRefreshGap = 5 * time.Minute
+	// userDelegationClockSkew backdates the key and SAS start time to tolerate clock differences with the service
+	userDelegationClockSkew = 5 * time.Minute
+)
+
+// UserDelegationKeyProvider mints user delegation credentials from an AAD token credential.
+// Implemented by azblob.ServiceURL created with a token credential pipeline.
+type UserDelegationKeyProvider interface {
+	GetUserDelegationCredential(ctx context.Context, info azblob.KeyInfo, timeout *int32, requestID *string) (azblob.UserDelegationCredential, error)
+}
+
+// AzureUserDelegationOptions configures the Azure walker to authorize requests using user delegation SAS
+type AzureUserDelegationOptions struct {
+	// KeyTTL is the validity period of each user delegation key, defaults to one hour
+	KeyTTL time.Duration
+	// RefreshBefore is how long before the key expires a new key is requested, defaults to five minutes
+	RefreshBefore time.Duration
+	// PresignAddress signs each entry Address with a read only SAS, so it can be accessed without credentials
+	PresignAddress bool
+}
+
+// userDelegationSigner caches a user delegation key and uses it to sign SAS query parameters
+type userDelegationSigner struct {
+	provider UserDelegationKeyProvider
+	opts     AzureUserDelegationOptions
+	now      func() time.Time
+
+	mu         sync.Mutex
+	credential *azblob.UserDelegationCredential
+	expiry     time.Time
+}
+
+func newUserDelegationSigner(provider UserDelegationKeyProvider, opts AzureUserDelegationOptions) *userDelegationSigner {
+	if opts.KeyTTL <= 0 {
+		opts.KeyTTL = defaultUserDelegationKeyTTL
+	}
+	if opts.RefreshBefore <= 0 {
+		opts.RefreshBefore = defaultUserDelegationRefreshGap
+	}
+	return &userDelegationSigner{
+		provider: provider,
+		opts:     opts,
+		now:      time.Now,
+	}
+}
+
+// getCredential returns the cached delegation credential, requesting a new one when missing or about to expire
+func (s *userDelegationSigner) getCredential(ctx context.Context) (azblob.UserDelegationCredential, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now().UTC()
+	if s.credential != nil && now.Add(s.opts.RefreshBefore).Before(s.expiry) {
+		return *s.credential, s.expiry, nil
+	}
+	expiry := now.Add(s.opts.KeyTTL)
+	credential, err := s.provider.GetUserDelegationCredential(ctx, azblob.KeyInfo{
+		Start:  now.Add(-userDelegationClockSkew).Format(azblob.SASTimeFormat),
+		Expiry: expiry.Format(azblob.SASTimeFormat),
+	}, nil, nil)
+	if err != nil {
+		return azblob.UserDelegationCredential{}, time.Time{}, fmt.Errorf("%w: user delegation key: %s", ErrAzureCredentials, err)
+	}
+	s.credential = &credential
+	s.expiry = expiry
+	return credential, expiry, nil
+}
+
+// sign returns the SAS query for the container, or for a single blob when blobName is set
+func (s *userDelegationSigner) sign(ctx context.Context, containerName, blobName, permissions string) (string, error) {
+	credential, expiry, err := s.getCredential(ctx)
+	if err != nil {
+		return "", err
+	}
+	sas, err := azblob.BlobSASSignatureValues{
+		Protocol:      azblob.SASProtocolHTTPS,
+		StartTime:     s.now().UTC().Add(-userDelegationClockSkew),
+		ExpiryTime:    expiry,
+		Permissions:   permissions,
+		ContainerName: containerName,
+		BlobName:      blobName,
+	}.NewSASQueryParameters(credential)
+	if err != nil {
+		return "", fmt.Errorf("%w: sign SAS: %s", ErrAzureCredentials, err)
+	}
+	return sas.Encode(), nil
+}
+
+// signContainerURL returns a copy of containerURL signed for listing and reading blobs
+func (s *userDelegationSigner) signContainerURL(ctx context.Context, containerURL *url.URL) (*url.URL, error) {
+	containerName := strings.TrimPrefix(containerURL.Path, "/")
+	query, err := s.sign(ctx, containerName, "", azblob.ContainerSASPermissions{List: true, Read: true}.String())
+	if err != nil {
+		return nil, err
+	}
+	signed := *containerURL
+	signed.RawQuery = query
+	return &signed, nil
+}
+
+// signBlobAddress returns the blob address with a read only SAS
+func (s *userDelegationSigner) signBlobAddress(ctx context.Context, containerURL *url.URL, blobName string) (string, error) {
+	containerName := strings.TrimPrefix(containerURL.Path, "/")
+	query, err := s.sign(ctx, containerName, blobName, azblob.BlobSASPermissions{Read: true}.String())
+	if err != nil {
+		return "", err
+	}
+	blobURL := getAzureBlobURL(containerURL, blobName)
+	blobURL.RawQuery = query
+	return blobURL.String(), nil
+}
@@ -0,0 +1,130 @@
+package store
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+type fakeDelegationKeyProvider struct {
+	mu    sync.Mutex
+	calls []azblob.KeyInfo
+}
+
+func (p *fakeDelegationKeyProvider) GetUserDelegationCredential(_ context.Context, info azblob.KeyInfo, _ *int32, _ *string) (azblob.UserDelegationCredential, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls = append(p.calls, info)
+	expiry, _ := time.Parse(azblob.SASTimeFormat, info.Expiry)
+	return azblob.NewUserDelegationCredential("account", azblob.UserDelegationKey{
+		SignedOid:     "object-id",
+		SignedTid:     "tenant-id",
+		SignedExpiry:  expiry,
+		SignedService: "b",
+		SignedVersion: azblob.SASVersion,
+		Value:         base64.StdEncoding.EncodeToString([]byte("delegation-key")),
+	}), nil
+}
+
+func (p *fakeDelegationKeyProvider) numCalls() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.calls)
+}
+
+func TestAzureWalkUserDelegation(t *testing.T) {
+	container := newFakeAzureContainer(2,
+		fakeAzureBlob{Name: "a", Content: []byte("a")},
+		fakeAzureBlob{Name: "b", Content: []byte("b")},
+		fakeAzureBlob{Name: "c", Content: []byte("c")},
+	)
+	var (
+		mu          sync.Mutex
+		listQueries []url.Values
+	)
+	container.handler = func(_ http.ResponseWriter, r *http.Request) bool {
+		if r.URL.Query().Get("comp") == "list" {
+			mu.Lock()
+			listQueries = append(listQueries, r.URL.Query())
+			mu.Unlock()
+		}
+		return false
+	}
+	fakeWalker, storageURI := newFakeAzureWalker(t, container)
+	provider := &fakeDelegationKeyProvider{}
+	walker := NewAzureBlobWalkerWithUserDelegation(fakeWalker.client, provider, AzureUserDelegationOptions{PresignAddress: true})
+
+	entries := walkAzure(t, walker, storageURI, WalkOptions{})
+	if len(entries) != 3 {
+		t.Fatalf("walked %d entries, expected 3", len(entries))
+	}
+	if provider.numCalls() != 1 {
+		t.Fatalf("requested %d delegation keys, expected a single cached key", provider.numCalls())
+	}
+	if len(listQueries) != 2 {
+		t.Fatalf("got %d list requests, expected 2 pages", len(listQueries))
+	}
+	for _, q := range listQueries {
+		if q.Get("sig") == "" || q.Get("skoid") != "object-id" || q.Get("sr") != "c" || q.Get("sp") != "rl" {
+			t.Fatalf("list request query %v, expected container user delegation SAS", q)
+		}
+	}
+	for _, e := range entries {
+		addr, err := url.Parse(e.Address)
+		if err != nil {
+			t.Fatalf("parse address '%s': %s", e.Address, err)
+		}
+		q := addr.Query()
+		if addr.Path != "/container/"+e.FullKey || q.Get("sig") == "" || q.Get("sr") != "b" || q.Get("sp") != "r" {
+			t.Fatalf("entry address '%s', expected blob read SAS", e.Address)
+		}
+	}
+}
+
+func TestUserDelegationSignerRefresh(t *testing.T) {
+	ctx := context.Background()
+	provider := &fakeDelegationKeyProvider{}
+	signer := newUserDelegationSigner(provider, AzureUserDelegationOptions{KeyTTL: time.Hour, RefreshBefore: 5 * time.Minute})
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	signer.now = func() time.Time { return now }
+
+	if _, err := signer.sign(ctx, "container", "", "rl"); err != nil {
+		t.Fatalf("sign: %s", err)
+	}
+	if provider.numCalls() != 1 {
+		t.Fatalf("requested %d delegation keys, expected 1", provider.numCalls())
+	}
+
+	// well before expiry the cached key is used
+	now = now.Add(30 * time.Minute)
+	if _, err := signer.sign(ctx, "container", "", "rl"); err != nil {
+		t.Fatalf("sign: %s", err)
+	}
+	if provider.numCalls() != 1 {
+		t.Fatalf("requested %d delegation keys before expiry, expected cached key", provider.numCalls())
+	}
+
+	// near expiry a new key is requested
+	now = now.Add(26 * time.Minute)
+	query, err := signer.sign(ctx, "container", "", "rl")
+	if err != nil {
+		t.Fatalf("sign: %s", err)
+	}
+	if provider.numCalls() != 2 {
+		t.Fatalf("requested %d delegation keys near expiry, expected refresh", provider.numCalls())
+	}
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		t.Fatalf("parse SAS '%s': %s", query, err)
+	}
+	expectedExpiry := now.Add(time.Hour).Format(azblob.SASTimeFormat)
+	if se := values.Get("se"); se != expectedExpiry {
+		t.Fatalf("SAS expiry %s, expected refreshed key expiry %s", se, expectedExpiry)
+	}
+}
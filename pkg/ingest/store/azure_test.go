@@ -0,0 +1,687 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/go-test/deep"
+)
+
+type fakeAzureBlob struct {
+	Name    string
+	Content []byte
+	// Properties holds extra listing properties by their XML element name (i.e. BlobType)
+	Properties map[string]string
+}
+
+// fakeAzureContainer serves the blob service list and download calls for a single container
+type fakeAzureContainer struct {
+	name     string
+	pageSize int
+	mu       sync.Mutex
+	blobs    map[string]fakeAzureBlob
+	listed   int
+	// handler, when set, can fully handle a request before the fake does by returning true
+	handler func(w http.ResponseWriter, r *http.Request) bool
+}
+
+func newFakeAzureContainer(pageSize int, blobs ...fakeAzureBlob) *fakeAzureContainer {
+	c := &fakeAzureContainer{
+		name:     "container",
+		pageSize: pageSize,
+		blobs:    make(map[string]fakeAzureBlob),
+	}
+	for _, b := range blobs {
+		c.blobs[b.Name] = b
+	}
+	return c
+}
+
+func (c *fakeAzureContainer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if c.handler != nil && c.handler(w, r) {
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/"+c.name)
+	if path == "" && r.URL.Query().Get("comp") == "list" {
+		c.list(w, r)
+		return
+	}
+	c.mu.Lock()
+	blob, ok := c.blobs[strings.TrimPrefix(path, "/")]
+	c.mu.Unlock()
+	if !ok {
+		w.Header().Set("x-ms-error-code", "BlobNotFound")
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(blob.Content)))
+	w.Header().Set("Last-Modified", time.Unix(0, 0).UTC().Format(http.TimeFormat))
+	w.Header().Set("ETag", `"`+blob.Name+`-etag"`)
+	for k, v := range blob.Properties {
+		w.Header().Set("x-ms-"+strings.ToLower(k), v)
+	}
+	if r.Method == http.MethodHead {
+		return
+	}
+	_, _ = w.Write(blob.Content)
+}
+
+func (c *fakeAzureContainer) list(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.listed++
+	query := r.URL.Query()
+	prefix := query.Get("prefix")
+	names := make([]string, 0, len(c.blobs))
+	for name := range c.blobs {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	offset := 0
+	if marker := query.Get("marker"); marker != "" {
+		offset = sort.SearchStrings(names, marker)
+	}
+	end := offset + c.pageSize
+	if end > len(names) {
+		end = len(names)
+	}
+	var sb strings.Builder
+	sb.WriteString(xml.Header)
+	sb.WriteString(`<EnumerationResults><Blobs>`)
+	for _, name := range names[offset:end] {
+		blob := c.blobs[name]
+		sb.WriteString("<Blob><Name>")
+		_ = xml.EscapeText(&sb, []byte(name))
+		sb.WriteString("</Name><Properties>")
+		fmt.Fprintf(&sb, "<Last-Modified>%s</Last-Modified>", time.Unix(0, 0).UTC().Format(http.TimeFormat))
+		fmt.Fprintf(&sb, "<Etag>%s-etag</Etag>", name)
+		fmt.Fprintf(&sb, "<Content-Length>%d</Content-Length>", len(blob.Content))
+		for k, v := range blob.Properties {
+			fmt.Fprintf(&sb, "<%s>", k)
+			_ = xml.EscapeText(&sb, []byte(v))
+			fmt.Fprintf(&sb, "</%s>", k)
+		}
+		sb.WriteString("</Properties></Blob>")
+	}
+	sb.WriteString("</Blobs>")
+	if end < len(names) {
+		fmt.Fprintf(&sb, "<NextMarker>%s</NextMarker>", names[end])
+	} else {
+		sb.WriteString("<NextMarker />")
+	}
+	sb.WriteString("</EnumerationResults>")
+	w.Header().Set("Content-Type", "application/xml")
+	_, _ = w.Write([]byte(sb.String()))
+}
+
+// newFakeAzureWalker starts a server for the fake container and returns a walker and the container storage URI
+func newFakeAzureWalker(t *testing.T, c *fakeAzureContainer) (*azureBlobWalker, *url.URL) {
+	t.Helper()
+	server := httptest.NewServer(c)
+	t.Cleanup(server.Close)
+	p := azblob.NewPipeline(azblob.NewAnonymousCredential(), azblob.PipelineOptions{
+		Retry: azblob.RetryOptions{MaxTries: 1},
+	})
+	walker, err := NewAzureBlobWalker(p)
+	if err != nil {
+		t.Fatalf("new azure walker: %s", err)
+	}
+	storageURI, err := url.Parse(server.URL + "/" + c.name + "/")
+	if err != nil {
+		t.Fatalf("parse server url: %s", err)
+	}
+	return walker, storageURI
+}
+
+func walkAzure(t *testing.T, walker *azureBlobWalker, storageURI *url.URL, op WalkOptions) []ObjectStoreEntry {
+	t.Helper()
+	var entries []ObjectStoreEntry
+	err := walker.Walk(context.Background(), storageURI, op, func(e ObjectStoreEntry) error {
+		entries = append(entries, e)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walk '%s': %s", storageURI, err)
+	}
+	return entries
+}
+
+func TestAzureWalkCopySource(t *testing.T) {
+	const copySource = "https://account.blob.core.windows.net/origin/source"
+	container := newFakeAzureContainer(10,
+		fakeAzureBlob{Name: "copied", Properties: map[string]string{"CopySource": copySource}},
+		fakeAzureBlob{Name: "original"},
+	)
+	var includes []string
+	container.handler = func(_ http.ResponseWriter, r *http.Request) bool {
+		includes = append(includes, r.URL.Query().Get("include"))
+		return false
+	}
+	walker, storageURI := newFakeAzureWalker(t, container)
+
+	entries := walkAzure(t, walker, storageURI, WalkOptions{IncludeCopySource: true})
+	if len(entries) != 2 {
+		t.Fatalf("walked %d entries, expected 2", len(entries))
+	}
+	if entries[0].CopySource != copySource {
+		t.Errorf("entry %s CopySource='%s', expected '%s'", entries[0].FullKey, entries[0].CopySource, copySource)
+	}
+	if entries[1].CopySource != "" {
+		t.Errorf("entry %s CopySource='%s', expected none", entries[1].FullKey, entries[1].CopySource)
+	}
+	if includes[0] != "copy" {
+		t.Errorf("list include='%s', expected copy details to be requested", includes[0])
+	}
+
+	entries = walkAzure(t, walker, storageURI, WalkOptions{})
+	if entries[0].CopySource != "" {
+		t.Errorf("entry %s CopySource='%s' without IncludeCopySource, expected none", entries[0].FullKey, entries[0].CopySource)
+	}
+}
+
+func TestAzureWalkBlobTypes(t *testing.T) {
+	container := newFakeAzureContainer(2,
+		fakeAzureBlob{Name: "append", Properties: map[string]string{"BlobType": string(azblob.BlobAppendBlob)}},
+		fakeAzureBlob{Name: "block1", Properties: map[string]string{"BlobType": string(azblob.BlobBlockBlob)}},
+		fakeAzureBlob{Name: "block2", Properties: map[string]string{"BlobType": string(azblob.BlobBlockBlob)}},
+		fakeAzureBlob{Name: "page", Properties: map[string]string{"BlobType": string(azblob.BlobPageBlob)}},
+	)
+	walker, storageURI := newFakeAzureWalker(t, container)
+
+	entries := walkAzure(t, walker, storageURI, WalkOptions{})
+	types := make(map[string]string)
+	for _, e := range entries {
+		types[e.FullKey] = e.BlobType
+	}
+	expected := map[string]string{
+		"append": "AppendBlob",
+		"block1": "BlockBlob",
+		"block2": "BlockBlob",
+		"page":   "PageBlob",
+	}
+	if diff := deep.Equal(types, expected); diff != nil {
+		t.Fatal("walked blob types didn't match:", diff)
+	}
+
+	entries = walkAzure(t, walker, storageURI, WalkOptions{BlobTypes: []string{"AppendBlob", "PageBlob"}})
+	if diff := deep.Equal(entriesKeys(entries), []string{"append", "page"}); diff != nil {
+		t.Fatal("filtered walk keys didn't match:", diff)
+	}
+	if skipped := walker.Marker().Skipped; skipped != 2 {
+		t.Fatalf("filtered walk skipped %d entries, expected the 2 block blobs", skipped)
+	}
+}
+
+func TestAzureWalkSkipMaxBytes(t *testing.T) {
+	blockBlob := map[string]string{"BlobType": string(azblob.BlobBlockBlob)}
+	pageBlob := map[string]string{"BlobType": string(azblob.BlobPageBlob)}
+	content := bytes.Repeat([]byte{'a'}, 100)
+	container := newFakeAzureContainer(10,
+		fakeAzureBlob{Name: "a", Content: content, Properties: blockBlob},
+		fakeAzureBlob{Name: "b", Content: content, Properties: pageBlob},
+		fakeAzureBlob{Name: "c", Content: content, Properties: blockBlob},
+		fakeAzureBlob{Name: "d", Content: content, Properties: pageBlob},
+		fakeAzureBlob{Name: "e", Content: content, Properties: blockBlob},
+	)
+	walker, storageURI := newFakeAzureWalker(t, container)
+	op := WalkOptions{MaxBytes: 150, BlobTypes: []string{string(azblob.BlobBlockBlob)}}
+	entries := walkAzure(t, walker, storageURI, op)
+	// entries after the walk stops are not counted, they are listed again on resume
+	mark := walker.Marker()
+	if diff := deep.Equal(entriesKeys(entries), []string{"a", "c"}); diff != nil || mark.LastKey != "c" || mark.Skipped != 1 {
+		t.Fatalf("walked %s (mark=%+v), expected a,c skipping only b", entriesKeys(entries), mark)
+	}
+	op.MaxBytes = 0
+	op.After = mark.LastKey
+	entries = walkAzure(t, walker, storageURI, op)
+	if diff := deep.Equal(entriesKeys(entries), []string{"e"}); diff != nil || walker.Marker().Skipped != 1 {
+		t.Fatalf("resumed walk %s (mark=%+v), expected e skipping d", entriesKeys(entries), walker.Marker())
+	}
+}
+
+func TestAzureWalkAddressStyle(t *testing.T) {
+	container := newFakeAzureContainer(10, fakeAzureBlob{Name: "path/to/blob"})
+	walker, storageURI := newFakeAzureWalker(t, container)
+
+	full := walkAzure(t, walker, storageURI, WalkOptions{})
+	expectedURL := storageURI.ResolveReference(&url.URL{Path: "path/to/blob"}).String()
+	if len(full) != 1 || full[0].Address != expectedURL {
+		t.Fatalf("full URL walk entries=%v, expected address '%s'", full, expectedURL)
+	}
+
+	keyOnly := walkAzure(t, walker, storageURI, WalkOptions{AddressStyle: AddressStyleKeyOnly})
+	if len(keyOnly) != 1 || keyOnly[0].Address != "path/to/blob" {
+		t.Fatalf("key only walk entries=%v, expected address 'path/to/blob'", keyOnly)
+	}
+	if keyOnly[0].FullKey != full[0].FullKey {
+		t.Fatalf("key only walk FullKey='%s', expected '%s'", keyOnly[0].FullKey, full[0].FullKey)
+	}
+}
+
+func TestAzureWalkPageTimeout(t *testing.T) {
+	container := newFakeAzureContainer(1, fakeAzureBlob{Name: "a"}, fakeAzureBlob{Name: "b"})
+	container.handler = func(_ http.ResponseWriter, r *http.Request) bool {
+		if r.URL.Query().Get("marker") != "" {
+			select {
+			case <-time.After(time.Second):
+			case <-r.Context().Done():
+			}
+		}
+		return false
+	}
+	walker, storageURI := newFakeAzureWalker(t, container)
+	err := walker.Walk(context.Background(), storageURI, WalkOptions{PageTimeout: 50 * time.Millisecond}, func(e ObjectStoreEntry) error {
+		return nil
+	})
+	if !errors.Is(err, ErrPageTimeout) {
+		t.Fatalf("walk err=%v, expected %s", err, ErrPageTimeout)
+	}
+	if mark := walker.Marker(); mark.LastKey != "a" || mark.Reason != CompletionReasonError {
+		t.Fatalf("mark=%+v, expected error after walking the first page", mark)
+	}
+}
+
+func TestAzureWalkMarkerExpired(t *testing.T) {
+	newContainer := func() *fakeAzureContainer {
+		container := newFakeAzureContainer(2,
+			fakeAzureBlob{Name: "a"}, fakeAzureBlob{Name: "b"}, fakeAzureBlob{Name: "c"},
+			fakeAzureBlob{Name: "d"}, fakeAzureBlob{Name: "e"},
+		)
+		expired := false
+		// expire the first continuation marker once
+		container.handler = func(w http.ResponseWriter, r *http.Request) bool {
+			if expired || r.URL.Query().Get("marker") == "" {
+				return false
+			}
+			expired = true
+			w.Header().Set("x-ms-error-code", string(azblob.ServiceCodeInvalidQueryParameterValue))
+			w.WriteHeader(http.StatusBadRequest)
+			return true
+		}
+		return container
+	}
+
+	walker, storageURI := newFakeAzureWalker(t, newContainer())
+	entries := walkAzure(t, walker, storageURI, WalkOptions{MaxListRestarts: 1})
+	if diff := deep.Equal(entriesKeys(entries), []string{"a", "b", "c", "d", "e"}); diff != nil {
+		t.Fatal("walk after an expired marker didn't match:", diff)
+	}
+	if walker.Marker().HasMore {
+		t.Fatal("expected walk to complete without more entries")
+	}
+
+	walker, storageURI = newFakeAzureWalker(t, newContainer())
+	err := walker.Walk(context.Background(), storageURI, WalkOptions{}, func(e ObjectStoreEntry) error { return nil })
+	if !isAzureMarkerExpired(err) {
+		t.Fatalf("walk without restarts err=%v, expected expired marker error", err)
+	}
+}
+
+func TestAzureWalkEntryErrorNotRestarted(t *testing.T) {
+	container := newFakeAzureContainer(2,
+		fakeAzureBlob{Name: "a"}, fakeAzureBlob{Name: "b"}, fakeAzureBlob{Name: "c"}, fakeAzureBlob{Name: "d"},
+	)
+	// fail reading the properties of an entry of the second page with the error of an expired marker
+	container.handler = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method != http.MethodHead || !strings.HasSuffix(r.URL.Path, "/c") {
+			return false
+		}
+		w.Header().Set("x-ms-error-code", string(azblob.ServiceCodeInvalidQueryParameterValue))
+		w.WriteHeader(http.StatusBadRequest)
+		return true
+	}
+	walker, storageURI := newFakeAzureWalker(t, container)
+	err := walker.Walk(context.Background(), storageURI, WalkOptions{MaxListRestarts: 1, IncludeReplicationStatus: true}, func(e ObjectStoreEntry) error {
+		return nil
+	})
+	if !isAzureMarkerExpired(err) {
+		t.Fatalf("walk err=%v, expected the entry properties error", err)
+	}
+	if container.listed != 2 {
+		t.Fatalf("listed %d pages, expected an entry error not to restart listing", container.listed)
+	}
+}
+
+func TestAzureWalkAccessControl(t *testing.T) {
+	newContainer := func(hns bool, calls *int32) *fakeAzureContainer {
+		container := newFakeAzureContainer(10, fakeAzureBlob{Name: "dir/a"}, fakeAzureBlob{Name: "dir/b"}, fakeAzureBlob{Name: "dir/c"})
+		container.handler = func(w http.ResponseWriter, r *http.Request) bool {
+			if r.URL.Query().Get("action") != "getAccessControl" {
+				return false
+			}
+			atomic.AddInt32(calls, 1)
+			if r.Method != http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return true
+			}
+			if !hns {
+				w.Header().Set("x-ms-error-code", "HierarchicalNamespaceNotEnabled")
+				w.WriteHeader(http.StatusBadRequest)
+				return true
+			}
+			name := strings.TrimPrefix(r.URL.Path, "/container/")
+			w.Header().Set("x-ms-owner", name+"-owner")
+			w.Header().Set("x-ms-group", "group")
+			w.Header().Set("x-ms-permissions", "rwxr-x---")
+			w.Header().Set("x-ms-acl", "user::rwx,group::r-x,other::---")
+			return true
+		}
+		return container
+	}
+
+	t.Run("hns", func(t *testing.T) {
+		var calls int32
+		walker, storageURI := newFakeAzureWalker(t, newContainer(true, &calls))
+		entries := walkAzure(t, walker, storageURI, WalkOptions{IncludeAccessControl: true, Concurrency: 2})
+		if len(entries) != 3 || calls != 3 {
+			t.Fatalf("walked %d entries with %d access control calls, expected 3 of each", len(entries), calls)
+		}
+		for _, e := range entries {
+			if e.Owner != e.FullKey+"-owner" || e.Group != "group" || e.Permissions != "rwxr-x---" || e.ACL != "user::rwx,group::r-x,other::---" {
+				t.Errorf("entry %s access control owner=%s group=%s permissions=%s acl=%s", e.FullKey, e.Owner, e.Group, e.Permissions, e.ACL)
+			}
+		}
+
+		calls = 0
+		entries = walkAzure(t, walker, storageURI, WalkOptions{})
+		if calls != 0 || entries[0].Owner != "" {
+			t.Fatalf("walk without IncludeAccessControl made %d access control calls (owner=%s), expected none", calls, entries[0].Owner)
+		}
+	})
+
+	t.Run("no_hns", func(t *testing.T) {
+		var calls int32
+		walker, storageURI := newFakeAzureWalker(t, newContainer(false, &calls))
+		entries := walkAzure(t, walker, storageURI, WalkOptions{IncludeAccessControl: true})
+		if len(entries) != 3 {
+			t.Fatalf("walked %d entries, expected 3", len(entries))
+		}
+		if calls != 1 {
+			t.Fatalf("made %d access control calls, expected to stop after the account is found to have no hierarchical namespace", calls)
+		}
+		for _, e := range entries {
+			if e.Owner != "" || e.Group != "" || e.Permissions != "" || e.ACL != "" {
+				t.Errorf("entry %s has access control set without hierarchical namespace", e.FullKey)
+			}
+		}
+	})
+}
+
+func TestAzureWalkReplicationStatus(t *testing.T) {
+	container := newFakeAzureContainer(10,
+		fakeAzureBlob{Name: "complete", Properties: map[string]string{"or-policy1_rule1": "complete", "or-policy2_rule1": "Complete"}},
+		fakeAzureBlob{Name: "failed", Properties: map[string]string{"or-policy1_rule1": "complete", "or-policy2_rule1": "failed"}},
+		fakeAzureBlob{Name: "none"},
+		fakeAzureBlob{Name: "pending", Properties: map[string]string{"or-policy1_rule1": "pending"}},
+		fakeAzureBlob{Name: "replica", Properties: map[string]string{"or-policy-id": "policy1"}},
+	)
+	walker, storageURI := newFakeAzureWalker(t, container)
+
+	entries := walkAzure(t, walker, storageURI, WalkOptions{IncludeReplicationStatus: true, Concurrency: 2})
+	expected := map[string]string{"complete": "complete", "failed": "failed", "none": "", "pending": "pending", "replica": ""}
+	if len(entries) != len(expected) {
+		t.Fatalf("walked %d entries, expected %d", len(entries), len(expected))
+	}
+	for _, e := range entries {
+		if e.ReplicationStatus != expected[e.FullKey] {
+			t.Errorf("entry %s replication status '%s', expected '%s'", e.FullKey, e.ReplicationStatus, expected[e.FullKey])
+		}
+	}
+
+	entries = walkAzure(t, walker, storageURI, WalkOptions{SkipIncompleteReplication: true})
+	keys := entriesKeys(entries)
+	if diff := deep.Equal(keys, []string{"complete", "none", "replica"}); diff != nil {
+		t.Fatalf("walked %s skipping incomplete replication, diff: %s", keys, diff)
+	}
+	if mark := walker.Marker(); mark.Skipped != 2 {
+		t.Fatalf("mark skipped %d entries, expected 2", mark.Skipped)
+	}
+
+	entries = walkAzure(t, walker, storageURI, WalkOptions{})
+	for _, e := range entries {
+		if e.ReplicationStatus != "" {
+			t.Fatalf("entry %s replication status '%s' without IncludeReplicationStatus", e.FullKey, e.ReplicationStatus)
+		}
+	}
+}
+
+func TestNormalizeETag(t *testing.T) {
+	tests := []struct {
+		etag     string
+		expected string
+	}{
+		{etag: "0x8D9F1A2B3C4D5E6", expected: "0x8D9F1A2B3C4D5E6"},
+		{etag: `"0x8D9F1A2B3C4D5E6"`, expected: "0x8D9F1A2B3C4D5E6"},
+		{etag: `W/"0x8D9F1A2B3C4D5E6"`, expected: "0x8D9F1A2B3C4D5E6"},
+		{etag: ` "9bb58f26192e4ba00f01e2e7b136bbd8" `, expected: "9bb58f26192e4ba00f01e2e7b136bbd8"},
+		{etag: "", expected: ""},
+	}
+	for _, tt := range tests {
+		if got := normalizeETag(tt.etag); got != tt.expected {
+			t.Errorf("normalizeETag(%q) = %q, expected %q", tt.etag, got, tt.expected)
+		}
+	}
+}
+
+func TestAzureWalkETag(t *testing.T) {
+	container := newFakeAzureContainer(10,
+		fakeAzureBlob{Name: "quoted", Properties: map[string]string{"Etag": `"0x8D9F1A2B3C4D5E6"`}},
+		fakeAzureBlob{Name: "weak", Properties: map[string]string{"Etag": `W/"0x8D9F1A2B3C4D5E7"`}},
+	)
+	walker, storageURI := newFakeAzureWalker(t, container)
+	expected := map[string]struct{ etag, raw string }{
+		"quoted": {etag: "0x8D9F1A2B3C4D5E6", raw: `"0x8D9F1A2B3C4D5E6"`},
+		"weak":   {etag: "0x8D9F1A2B3C4D5E7", raw: `W/"0x8D9F1A2B3C4D5E7"`},
+	}
+
+	entries := walkAzure(t, walker, storageURI, WalkOptions{IncludeRawETag: true})
+	if len(entries) != len(expected) {
+		t.Fatalf("walked %d entries, expected %d", len(entries), len(expected))
+	}
+	for _, e := range entries {
+		if e.ETag != expected[e.FullKey].etag || e.RawETag != expected[e.FullKey].raw {
+			t.Errorf("entry %s ETag=%s RawETag=%s, expected ETag=%s RawETag=%s", e.FullKey, e.ETag, e.RawETag, expected[e.FullKey].etag, expected[e.FullKey].raw)
+		}
+	}
+
+	entries = walkAzure(t, walker, storageURI, WalkOptions{})
+	for _, e := range entries {
+		if e.ETag != expected[e.FullKey].etag || e.RawETag != "" {
+			t.Errorf("entry %s ETag=%s RawETag=%s without IncludeRawETag, expected ETag=%s and no RawETag", e.FullKey, e.ETag, e.RawETag, expected[e.FullKey].etag)
+		}
+	}
+}
+
+func TestAzureWalkExactMatch(t *testing.T) {
+	container := newFakeAzureContainer(10,
+		fakeAzureBlob{Name: "dir/data", Content: []byte("exact")},
+		fakeAzureBlob{Name: "dir/data.csv", Content: []byte("sibling")},
+		fakeAzureBlob{Name: "dir/data/nested", Content: []byte("nested")},
+	)
+	walker, storageURI := newFakeAzureWalker(t, container)
+	blobURI := storageURI.ResolveReference(&url.URL{Path: "dir/data"})
+
+	entries := walkAzure(t, walker, blobURI, WalkOptions{ExactMatch: true, IncludeRawETag: true})
+	if len(entries) != 1 {
+		t.Fatalf("walked %s, expected only the exact match", entriesKeys(entries))
+	}
+	e := entries[0]
+	if e.FullKey != "dir/data" || e.RelativeKey != "data" || e.Size != 5 || e.ETag != "dir/data-etag" || e.RawETag != `"dir/data-etag"` {
+		t.Fatalf("exact match entry %s (raw etag %s), expected dir/data", e, e.RawETag)
+	}
+	if container.listed != 0 {
+		t.Fatalf("listed %d times, expected exact match without listing", container.listed)
+	}
+	if mark := walker.Marker(); mark.HasMore || mark.Reason != CompletionReasonCompleted {
+		t.Fatalf("mark %+v, expected completed walk", mark)
+	}
+
+	missingURI := storageURI.ResolveReference(&url.URL{Path: "dir/dat"})
+	entries = walkAzure(t, walker, missingURI, WalkOptions{ExactMatch: true})
+	if len(entries) != 0 {
+		t.Fatalf("walked %s for a missing blob, expected nothing", entriesKeys(entries))
+	}
+}
+
+func TestAzureWalkEncryption(t *testing.T) {
+	container := newFakeAzureContainer(10,
+		fakeAzureBlob{Name: "default"},
+		fakeAzureBlob{Name: "scope1", Properties: map[string]string{"EncryptionScope": "scope1"}},
+		fakeAzureBlob{Name: "scope2", Properties: map[string]string{"EncryptionScope": "scope2"}},
+		fakeAzureBlob{Name: "cpk", Properties: map[string]string{"CustomerProvidedKeySha256": "a2V5LXNoYTI1Ng=="}},
+	)
+	walker, storageURI := newFakeAzureWalker(t, container)
+
+	entries := walkAzure(t, walker, storageURI, WalkOptions{})
+	type encryption struct {
+		scope string
+		cpk   bool
+	}
+	expected := map[string]encryption{
+		"default": {},
+		"scope1":  {scope: "scope1"},
+		"scope2":  {scope: "scope2"},
+		"cpk":     {cpk: true},
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("walked %d entries, expected %d", len(entries), len(expected))
+	}
+	for _, e := range entries {
+		if got := (encryption{scope: e.EncryptionScope, cpk: e.CustomerProvidedKey}); got != expected[e.FullKey] {
+			t.Errorf("entry %s encryption %+v, expected %+v", e.FullKey, got, expected[e.FullKey])
+		}
+	}
+
+	tests := []struct {
+		name     string
+		op       WalkOptions
+		expected []string
+	}{
+		{name: "scopes", op: WalkOptions{EncryptionScopes: []string{"scope1", "scope2"}}, expected: []string{"scope1", "scope2"}},
+		{name: "default_scope", op: WalkOptions{EncryptionScopes: []string{""}}, expected: []string{"cpk", "default"}},
+		{name: "customer_provided_key", op: WalkOptions{RequireCustomerProvidedKey: true}, expected: []string{"cpk"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys := entriesKeys(walkAzure(t, walker, storageURI, tt.op))
+			if diff := deep.Equal(keys, tt.expected); diff != nil {
+				t.Fatalf("walked %s, diff: %s", keys, diff)
+			}
+			if skipped := walker.Marker().Skipped; skipped != int64(len(expected)-len(tt.expected)) {
+				t.Fatalf("mark skipped %d entries, expected %d", skipped, len(expected)-len(tt.expected))
+			}
+		})
+	}
+}
+
+func TestAzureWalkPageBoundaryMark(t *testing.T) {
+	var blobs []fakeAzureBlob
+	var keys []string
+	for i := 0; i < 10; i++ {
+		name := fmt.Sprintf("blob%02d", i)
+		blobs = append(blobs, fakeAzureBlob{Name: name, Content: []byte("data")})
+		keys = append(keys, name)
+	}
+	walker, storageURI := newFakeAzureWalker(t, newFakeAzureContainer(3, blobs...))
+
+	// fail in the middle of the second page, the mark stays at the end of the first page
+	errStop := errors.New("stop")
+	var walked []string
+	err := walker.Walk(context.Background(), storageURI, WalkOptions{PageBoundaryMark: true}, func(e ObjectStoreEntry) error {
+		if e.FullKey == "blob04" {
+			return errStop
+		}
+		walked = append(walked, e.FullKey)
+		return nil
+	})
+	if !errors.Is(err, errStop) {
+		t.Fatalf("walk err=%v, expected %s", err, errStop)
+	}
+	mark := walker.Marker()
+	if mark.LastKey != "blob02" || mark.ContinuationToken == "" || !mark.HasMore {
+		t.Fatalf("mark=%+v, expected the first page boundary", mark)
+	}
+
+	// resume from the mark, the walk continues at the second page
+	resumed := walkAzure(t, walker, storageURI, WalkOptions{
+		After:             mark.LastKey,
+		ContinuationToken: mark.ContinuationToken,
+		PageBoundaryMark:  true,
+	})
+	if diff := deep.Equal(append(walked[:3], entriesKeys(resumed)...), keys); diff != nil {
+		t.Fatal("walk resumed from the page boundary didn't match:", diff)
+	}
+	if walker.Marker().HasMore {
+		t.Fatal("expected resumed walk to complete without more entries")
+	}
+
+	// a limited walk ends at the page boundary, resuming it walks the following pages
+	var limited []string
+	op := WalkOptions{PageBoundaryMark: true, MaxBytes: 1}
+	for i := 0; i < len(keys); i++ {
+		entries := walkAzure(t, walker, storageURI, op)
+		limited = append(limited, entriesKeys(entries)...)
+		mark := walker.Marker()
+		if !mark.HasMore {
+			break
+		}
+		if mark.Reason != CompletionReasonLimitReached || mark.LastKey != entries[len(entries)-1].FullKey {
+			t.Fatalf("mark=%+v after walking %v, expected limit reached at the page boundary", mark, entriesKeys(entries))
+		}
+		op.After = mark.LastKey
+		op.ContinuationToken = mark.ContinuationToken
+	}
+	if diff := deep.Equal(limited, keys); diff != nil {
+		t.Fatal("limited walks resumed from page boundaries didn't match:", diff)
+	}
+}
+
+func TestWalkPhysicalAddress(t *testing.T) {
+	keys := []string{"dir/file", "dir/sub/file with space", "file"}
+	var blobs []fakeAzureBlob
+	objects := make(map[string]int64)
+	for _, key := range keys {
+		blobs = append(blobs, fakeAzureBlob{Name: key})
+		objects[key] = 1
+	}
+	azureWalker, storageURI := newFakeAzureWalker(t, newFakeAzureContainer(2, blobs...))
+	s3Walker := &s3Walker{s3: newFakeS3(2, objects)}
+
+	for _, style := range []AddressStyle{AddressStyleFullURL, AddressStyleKeyOnly} {
+		azureEntries := walkAzure(t, azureWalker, storageURI, WalkOptions{AddressStyle: style})
+		s3Entries := walkS3(t, s3Walker, "s3://bucket/", WalkOptions{AddressStyle: style})
+		if len(azureEntries) != len(keys) || len(s3Entries) != len(keys) {
+			t.Fatalf("walked %d azure and %d s3 entries, expected %d", len(azureEntries), len(s3Entries), len(keys))
+		}
+		azurePrefix := "https://" + storageURI.Host + "/container/"
+		for i, key := range keys {
+			if expected := azurePrefix + key; azureEntries[i].PhysicalAddress != expected {
+				t.Errorf("azure %s physical address=%s, expected %s", key, azureEntries[i].PhysicalAddress, expected)
+			}
+			if expected := "s3://bucket/" + key; s3Entries[i].PhysicalAddress != expected {
+				t.Errorf("s3 %s physical address=%s, expected %s", key, s3Entries[i].PhysicalAddress, expected)
+			}
+			azureKey := strings.TrimPrefix(azureEntries[i].PhysicalAddress, azurePrefix)
+			s3Key := strings.TrimPrefix(s3Entries[i].PhysicalAddress, "s3://bucket/")
+			if azureKey != s3Key {
+				t.Errorf("physical address key azure=%s s3=%s, expected the same key", azureKey, s3Key)
+			}
+		}
+	}
+}
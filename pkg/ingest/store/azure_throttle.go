@@ -0,0 +1,48 @@
+package store
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+)
+
+// throttledPipeline sends the requests of a pipeline at the rate of an adaptive throttle. The throttle policy is
+// added next to the request method policy, under the retry policy, so every try is throttled and observed.
+type throttledPipeline struct {
+	pipeline.Pipeline
+	throttle *AdaptiveThrottle
+}
+
+func (p *throttledPipeline) Do(ctx context.Context, methodFactory pipeline.Factory, request pipeline.Request) (pipeline.Response, error) {
+	factory := pipeline.FactoryFunc(func(next pipeline.Policy, po *pipeline.PolicyOptions) pipeline.PolicyFunc {
+		if methodFactory != nil {
+			next = methodFactory.New(next, po)
+		}
+		return func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+			sentAt, err := p.throttle.wait(ctx)
+			if err != nil {
+				return nil, err
+			}
+			resp, err := next.Do(ctx, request)
+			if resp != nil && resp.Response() != nil {
+				p.throttle.observe(sentAt, isAzureThrottled(resp.Response().StatusCode))
+			}
+			return resp, err
+		}
+	})
+	return p.Pipeline.Do(ctx, factory, request)
+}
+
+// isAzureThrottled reports if the service throttled the request, failing it with status code
+func isAzureThrottled(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode == http.StatusServiceUnavailable
+}
+
+// pipeline returns the pipeline used to send requests, throttled when the walk sets a throttle
+func (a *azureBlobWalker) pipeline() pipeline.Pipeline {
+	if a.throttle == nil {
+		return a.client
+	}
+	return &throttledPipeline{Pipeline: a.client, throttle: a.throttle}
+}
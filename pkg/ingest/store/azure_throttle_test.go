@@ -0,0 +1,103 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+)
+
+// fakeThrottlingPipeline sends requests to handler, throttling them with 503 responses when more than limit
+// requests are sent within window. Throttled requests are retried, as the blob pipeline retry policy does.
+type fakeThrottlingPipeline struct {
+	handler http.Handler
+	clock   *fakeClock
+	limit   int
+	window  time.Duration
+
+	mu sync.Mutex
+	// sent holds the send times of the requests within the window
+	sent      []time.Time
+	requests  int
+	succeeded []time.Time
+}
+
+func (p *fakeThrottlingPipeline) Do(ctx context.Context, methodFactory pipeline.Factory, request pipeline.Request) (pipeline.Response, error) {
+	for {
+		resp, err := methodFactory.New(pipeline.PolicyFunc(p.send), &pipeline.PolicyOptions{}).Do(ctx, request)
+		if resp == nil || !isAzureThrottled(resp.Response().StatusCode) {
+			return resp, err
+		}
+	}
+}
+
+func (p *fakeThrottlingPipeline) send(_ context.Context, request pipeline.Request) (pipeline.Response, error) {
+	now := p.clock.Now()
+	p.mu.Lock()
+	for len(p.sent) > 0 && now.Sub(p.sent[0]) >= p.window {
+		p.sent = p.sent[1:]
+	}
+	throttled := len(p.sent) >= p.limit
+	p.sent = append(p.sent, now)
+	p.requests++
+	if !throttled {
+		p.succeeded = append(p.succeeded, now)
+	}
+	p.mu.Unlock()
+
+	rec := httptest.NewRecorder()
+	if throttled {
+		rec.Header().Set("x-ms-error-code", "ServerBusy")
+		rec.WriteHeader(http.StatusServiceUnavailable)
+	} else {
+		p.handler.ServeHTTP(rec, request.Request)
+	}
+	resp := rec.Result()
+	resp.Request = request.Request
+	return pipeline.NewHTTPResponse(resp), nil
+}
+
+func TestAzureWalkThrottle(t *testing.T) {
+	const (
+		blobs = 1000
+		// the service throttles above 100 requests per second
+		limit  = 10
+		window = 100 * time.Millisecond
+		maxRPS = float64(limit) * float64(time.Second/window)
+	)
+	var content []fakeAzureBlob
+	for i := 0; i < blobs; i++ {
+		content = append(content, fakeAzureBlob{Name: fmt.Sprintf("blob%04d", i)})
+	}
+	container := newFakeAzureContainer(1, content...)
+	throttle, clock := newFakeClockThrottle(t, 1, 10*maxRPS)
+	p := &fakeThrottlingPipeline{handler: container, clock: clock, limit: limit, window: window}
+	walker, err := NewAzureBlobWalker(p)
+	if err != nil {
+		t.Fatalf("new azure walker: %s", err)
+	}
+	storageURI, _ := url.Parse("https://account.blob.core.windows.net/" + container.name + "/")
+
+	entries := walkAzure(t, walker, storageURI, WalkOptions{Throttle: throttle})
+	if len(entries) != blobs {
+		t.Fatalf("walked %d entries, expected %d", len(entries), blobs)
+	}
+
+	// the effective rate, after the rate converged, should be below the service limit
+	converged := p.succeeded[len(p.succeeded)/2:]
+	elapsed := converged[len(converged)-1].Sub(converged[0])
+	rate := float64(len(converged)-1) / elapsed.Seconds()
+	if rate > maxRPS || rate < maxRPS/4 {
+		t.Fatalf("effective rate %.1f requests/s, expected below the %.0f requests/s limit and above a quarter of it", rate, maxRPS)
+	}
+	throttledRatio := float64(p.requests-len(p.succeeded)) / float64(p.requests)
+	if throttledRatio > 0.1 {
+		t.Fatalf("%.0f%% of the requests were throttled, expected the throttle to avoid most", throttledRatio*100)
+	}
+}
@@ -0,0 +1,50 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+var ErrInvalidBatchSize = errors.New("invalid batch size")
+
+// WalkBatch walks storageURI using walker and passes the entries to batchFn in batches of batchSize entries, the
+// last batch holds the remaining entries and may be smaller. The walk stops between batches once ctx is done.
+// Returns the FullKey of the last entry of the last batch batchFn succeeded on, or op.After if none. The walker Marker() may be past entries that were
+// buffered and not passed to batchFn when the walk stops early, resume a stopped walk using WalkOptions.After set
+// to the returned key instead.
+func WalkBatch(ctx context.Context, walker Walker, storageURI *url.URL, op WalkOptions, batchSize int, batchFn func([]ObjectStoreEntry) error) (string, error) {
+	if batchSize < 1 {
+		return "", fmt.Errorf("%w: %d", ErrInvalidBatchSize, batchSize)
+	}
+	lastKey := op.After
+	batch := make([]ObjectStoreEntry, 0, batchSize)
+	flush := func() error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		err := batchFn(batch)
+		if err == nil {
+			lastKey = batch[len(batch)-1].FullKey
+		}
+		// a new slice, batchFn may keep the previous batch
+		batch = make([]ObjectStoreEntry, 0, batchSize)
+		return err
+	}
+	err := walker.Walk(ctx, storageURI, op, func(e ObjectStoreEntry) error {
+		batch = append(batch, e)
+		if len(batch) < batchSize {
+			return nil
+		}
+		return flush()
+	})
+	if err != nil {
+		return lastKey, err
+	}
+	if len(batch) == 0 {
+		return lastKey, nil
+	}
+	err = flush()
+	return lastKey, err
+}
@@ -0,0 +1,119 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+func TestWalkBatch(t *testing.T) {
+	objects := make(map[string]int64)
+	for i := 0; i < 7; i++ {
+		objects[fmt.Sprintf("obj%02d", i)] = 1
+	}
+	storageURI, _ := url.Parse("s3://bucket/")
+
+	t.Run("batches", func(t *testing.T) {
+		walker := &s3Walker{s3: newFakeS3(2, objects)}
+		var sizes []int
+		var keys []string
+		_, err := WalkBatch(context.Background(), walker, storageURI, WalkOptions{}, 3, func(batch []ObjectStoreEntry) error {
+			sizes = append(sizes, len(batch))
+			keys = append(keys, entriesKeys(batch)...)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("WalkBatch: %s", err)
+		}
+		if diff := deep.Equal(sizes, []int{3, 3, 1}); diff != nil {
+			t.Fatal("batch sizes didn't match:", diff)
+		}
+		if len(keys) != len(objects) || keys[0] != "obj00" || keys[len(keys)-1] != "obj06" {
+			t.Fatalf("batched keys %v, expected all objects in order", keys)
+		}
+	})
+
+	t.Run("exact_batches", func(t *testing.T) {
+		walker := &s3Walker{s3: newFakeS3(2, objects)}
+		var sizes []int
+		_, err := WalkBatch(context.Background(), walker, storageURI, WalkOptions{}, 7, func(batch []ObjectStoreEntry) error {
+			sizes = append(sizes, len(batch))
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("WalkBatch: %s", err)
+		}
+		if diff := deep.Equal(sizes, []int{7}); diff != nil {
+			t.Fatal("batch sizes didn't match, expected no empty final batch:", diff)
+		}
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		walker := &s3Walker{s3: newFakeS3(2, objects)}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		batches := 0
+		_, err := WalkBatch(ctx, walker, storageURI, WalkOptions{}, 2, func(batch []ObjectStoreEntry) error {
+			batches++
+			cancel()
+			return nil
+		})
+		if !errors.Is(err, context.Canceled) || batches != 1 {
+			t.Fatalf("WalkBatch err=%v after %d batches, expected cancel after the first batch", err, batches)
+		}
+	})
+
+	t.Run("resume", func(t *testing.T) {
+		walker := &s3Walker{s3: newFakeS3(2, objects)}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		// cancel while obj03 and obj04 are buffered, before the second batch is passed to batchFn
+		op := WalkOptions{Skip: func(e ObjectStoreEntry) bool {
+			if e.FullKey == "obj04" {
+				cancel()
+			}
+			return false
+		}}
+		var keys []string
+		lastKey, err := WalkBatch(ctx, walker, storageURI, op, 3, func(batch []ObjectStoreEntry) error {
+			keys = append(keys, entriesKeys(batch)...)
+			return nil
+		})
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("WalkBatch err=%v, expected %s", err, context.Canceled)
+		}
+		if lastKey != "obj02" {
+			t.Fatalf("WalkBatch last key=%s, expected the last key of the first batch", lastKey)
+		}
+		if mark := walker.Marker(); mark.LastKey <= lastKey {
+			t.Fatalf("walker mark=%+v, expected it past the undelivered entries", mark)
+		}
+
+		walker = &s3Walker{s3: newFakeS3(2, objects)}
+		lastKey, err = WalkBatch(context.Background(), walker, storageURI, WalkOptions{After: lastKey}, 3, func(batch []ObjectStoreEntry) error {
+			keys = append(keys, entriesKeys(batch)...)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("resume WalkBatch: %s", err)
+		}
+		if lastKey != "obj06" {
+			t.Fatalf("resumed WalkBatch last key=%s, expected obj06", lastKey)
+		}
+		if diff := deep.Equal(keys, []string{"obj00", "obj01", "obj02", "obj03", "obj04", "obj05", "obj06"}); diff != nil {
+			t.Fatal("batched keys after resume didn't match:", diff)
+		}
+	})
+
+	t.Run("invalid_size", func(t *testing.T) {
+		walker := &s3Walker{s3: newFakeS3(2, objects)}
+		_, err := WalkBatch(context.Background(), walker, storageURI, WalkOptions{}, 0, func([]ObjectStoreEntry) error { return nil })
+		if !errors.Is(err, ErrInvalidBatchSize) {
+			t.Fatalf("WalkBatch err=%v, expected %s", err, ErrInvalidBatchSize)
+		}
+	})
+}
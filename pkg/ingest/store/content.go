@@ -0,0 +1,88 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash/crc32"
+	"io"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// crc32cTable is the Castagnoli polynomial table, the same checksum GCS computes for objects
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// objectOpener opens the content of a walked entry for reading
+type objectOpener func(ctx context.Context, e ObjectStoreEntry) (io.ReadCloser, error)
+
+// forEachEntry calls fn for each of the entries, up to concurrency entries in parallel. fn may update the entry it
+// is called with.
+func forEachEntry(ctx context.Context, entries []ObjectStoreEntry, concurrency int, fn func(ctx context.Context, e *ObjectStoreEntry) error) error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	g, ctx := errgroup.WithContext(ctx)
+	sem := make(chan struct{}, concurrency)
+	for i := range entries {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			if err := g.Wait(); err != nil {
+				return err
+			}
+			return ctx.Err()
+		}
+		e := &entries[i]
+		g.Go(func() error {
+			defer func() { <-sem }()
+			return fn(ctx, e)
+		})
+	}
+	return g.Wait()
+}
+
+// readEntries reads the content of the entries using open and passes it to readFn.
+// Up to concurrency entries are read in parallel, readFn may update the entry it is called with.
+func readEntries(ctx context.Context, entries []ObjectStoreEntry, concurrency int, open objectOpener, readFn func(e *ObjectStoreEntry, r io.Reader) error) error {
+	return forEachEntry(ctx, entries, concurrency, func(ctx context.Context, e *ObjectStoreEntry) error {
+		body, err := open(ctx, *e)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = body.Close() }()
+		return readFn(e, body)
+	})
+}
+
+// computeCRC32C reads the entries content and sets their CRC32C
+func computeCRC32C(ctx context.Context, entries []ObjectStoreEntry, concurrency int, open objectOpener) error {
+	return readEntries(ctx, entries, concurrency, open, func(e *ObjectStoreEntry, r io.Reader) error {
+		h := crc32.New(crc32cTable)
+		if _, err := io.Copy(h, r); err != nil {
+			return err
+		}
+		e.CRC32C = h.Sum32()
+		return nil
+	})
+}
+
+// computeSHA256 reads the content of the entries smaller than belowBytes and sets their SHA256
+func computeSHA256(ctx context.Context, entries []ObjectStoreEntry, belowBytes int64, concurrency int, open objectOpener) error {
+	return forEachEntry(ctx, entries, concurrency, func(ctx context.Context, e *ObjectStoreEntry) error {
+		if e.Size >= belowBytes {
+			return nil
+		}
+		body, err := open(ctx, *e)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = body.Close() }()
+		h := sha256.New()
+		if _, err := io.Copy(h, body); err != nil {
+			return err
+		}
+		e.SHA256 = hex.EncodeToString(h.Sum(nil))
+		return nil
+	})
+}
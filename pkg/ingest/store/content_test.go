@@ -0,0 +1,125 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"testing"
+)
+
+// crc32c check value of "123456789"
+const checkCRC32C = 0xe3069283
+
+func TestWalkComputeCRC32C(t *testing.T) {
+	contents := make(map[string][]byte)
+	for i := 0; i < 5; i++ {
+		contents[fmt.Sprintf("data/obj%d", i)] = []byte("123456789")
+	}
+	contents["data/empty"] = []byte{}
+
+	t.Run("s3", func(t *testing.T) {
+		walker := &s3Walker{s3: newFakeS3WithContent(2, contents)}
+		entries := walkS3(t, walker, "s3://bucket/data/", WalkOptions{ComputeCRC32C: true, Concurrency: 3})
+		verifyCRC32C(t, entries)
+	})
+
+	t.Run("azure", func(t *testing.T) {
+		var blobs []fakeAzureBlob
+		for name, content := range contents {
+			blobs = append(blobs, fakeAzureBlob{Name: name, Content: content})
+		}
+		walker, storageURI := newFakeAzureWalker(t, newFakeAzureContainer(2, blobs...))
+		entries := walkAzure(t, walker, storageURI, WalkOptions{ComputeCRC32C: true, Concurrency: 2})
+		verifyCRC32C(t, entries)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		fake := newFakeS3WithContent(2, contents)
+		entries := walkS3(t, &s3Walker{s3: fake}, "s3://bucket/data/", WalkOptions{})
+		for _, e := range entries {
+			if e.CRC32C != 0 {
+				t.Errorf("entry %s CRC32C=%x, expected no checksum", e.FullKey, e.CRC32C)
+			}
+		}
+		if len(fake.fetched) != 0 {
+			t.Errorf("fetched %v, expected no content to be read", fake.fetched)
+		}
+	})
+}
+
+func verifyCRC32C(t *testing.T, entries []ObjectStoreEntry) {
+	t.Helper()
+	if len(entries) != 6 {
+		t.Fatalf("walked %d entries, expected 6", len(entries))
+	}
+	for _, e := range entries {
+		expected := uint32(checkCRC32C)
+		if e.Size == 0 {
+			expected = 0
+		}
+		if e.CRC32C != expected {
+			t.Errorf("entry %s CRC32C=%x, expected %x", e.FullKey, e.CRC32C, expected)
+		}
+	}
+}
+
+func TestWalkHashBelowBytes(t *testing.T) {
+	const (
+		smallContent = "small"
+		// sha256 of smallContent
+		smallSHA256 = "81db8ebbbbc69c6c6ad4a6aa92b76e0c08af547da236b9e2c9dbe1d8285a8130"
+	)
+	contents := map[string][]byte{
+		"data/small1": []byte(smallContent),
+		"data/small2": []byte(smallContent),
+		"data/large":  []byte("large content, over the threshold"),
+	}
+	verify := func(t *testing.T, entries []ObjectStoreEntry) {
+		t.Helper()
+		if len(entries) != len(contents) {
+			t.Fatalf("walked %d entries, expected %d", len(entries), len(contents))
+		}
+		for _, e := range entries {
+			expected := smallSHA256
+			if e.FullKey == "data/large" {
+				expected = ""
+			}
+			if e.SHA256 != expected {
+				t.Errorf("entry %s SHA256=%s, expected '%s'", e.FullKey, e.SHA256, expected)
+			}
+		}
+	}
+	op := WalkOptions{HashBelowBytes: 10, Concurrency: 2}
+
+	t.Run("s3", func(t *testing.T) {
+		fake := newFakeS3WithContent(2, contents)
+		entries := walkS3(t, &s3Walker{s3: fake}, "s3://bucket/data/", op)
+		verify(t, entries)
+		for _, key := range fake.fetched {
+			if key == "data/large" {
+				t.Errorf("read content of %s, expected large objects not to be read", key)
+			}
+		}
+	})
+
+	t.Run("azure", func(t *testing.T) {
+		var blobs []fakeAzureBlob
+		for name, content := range contents {
+			blobs = append(blobs, fakeAzureBlob{Name: name, Content: content})
+		}
+		walker, storageURI := newFakeAzureWalker(t, newFakeAzureContainer(2, blobs...))
+		verify(t, walkAzure(t, walker, storageURI, op))
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		storageURI, _ := url.Parse("s3://bucket/data/")
+		walker := &s3Walker{s3: newFakeS3WithContent(2, contents)}
+		err := walker.Walk(ctx, storageURI, op, func(e ObjectStoreEntry) error { return nil })
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("walk with cancelled context err=%v, expected %s", err, context.Canceled)
+		}
+	})
+}
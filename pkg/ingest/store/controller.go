@@ -0,0 +1,65 @@
+package store
+
+import (
+	"context"
+	"sync"
+)
+
+// WalkController pauses and resumes walks using it. While paused, walkers don't issue list calls and wait before
+// walking the next page, the walker Mark is kept valid for the whole pause.
+// The zero value is a running (not paused) controller.
+type WalkController struct {
+	mu      sync.Mutex
+	paused  bool
+	resumed chan struct{}
+}
+
+// Pause stops walks using the controller before their next list call. Pausing a paused controller has no effect.
+func (c *WalkController) Pause() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.paused {
+		return
+	}
+	c.paused = true
+	c.resumed = make(chan struct{})
+}
+
+// Resume continues the paused walks from their current position. Resuming a running controller has no effect.
+func (c *WalkController) Resume() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.paused {
+		return
+	}
+	c.paused = false
+	close(c.resumed)
+}
+
+// Paused reports if the controller is paused
+func (c *WalkController) Paused() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.paused
+}
+
+// wait blocks while the controller is paused, returning the context error if it is done first.
+// A nil controller never blocks.
+func (c *WalkController) wait(ctx context.Context) error {
+	if c == nil {
+		return ctx.Err()
+	}
+	c.mu.Lock()
+	if !c.paused {
+		c.mu.Unlock()
+		return ctx.Err()
+	}
+	resumed := c.resumed
+	c.mu.Unlock()
+	select {
+	case <-resumed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,81 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestWalkControllerPauseResume(t *testing.T) {
+	objects := make(map[string]int64)
+	for i := 0; i < 9; i++ {
+		objects[fmt.Sprintf("obj%02d", i)] = 1
+	}
+	fake := newFakeS3(3, objects)
+	walker := &s3Walker{s3: fake}
+	storageURI, _ := url.Parse("s3://bucket/")
+	ctrl := &WalkController{}
+	paused := make(chan struct{})
+	done := make(chan error, 1)
+	var walked []string
+	go func() {
+		done <- walker.Walk(context.Background(), storageURI, WalkOptions{Controller: ctrl}, func(e ObjectStoreEntry) error {
+			walked = append(walked, e.FullKey)
+			if e.FullKey == "obj02" {
+				ctrl.Pause()
+				close(paused)
+			}
+			return nil
+		})
+	}()
+
+	<-paused
+	time.Sleep(50 * time.Millisecond)
+	if n := fake.numListed(); n != 1 {
+		t.Fatalf("listed %d pages while paused, expected only the first page", n)
+	}
+	select {
+	case err := <-done:
+		t.Fatalf("walk completed while paused: %v", err)
+	default:
+	}
+	if mark := walker.Marker(); !mark.HasMore || mark.LastKey != "obj02" {
+		t.Fatalf("mark=%+v while paused, expected the last walked key of the first page", mark)
+	}
+
+	ctrl.Resume()
+	if err := <-done; err != nil {
+		t.Fatalf("walk after resume: %s", err)
+	}
+	if len(walked) != len(objects) || fake.numListed() != 3 {
+		t.Fatalf("walked %d entries in %d pages, expected %d entries in 3 pages", len(walked), fake.numListed(), len(objects))
+	}
+	if walker.Marker().HasMore {
+		t.Fatal("expected walk to complete without more entries")
+	}
+}
+
+func TestWalkControllerCancelWhilePaused(t *testing.T) {
+	walker := &s3Walker{s3: newFakeS3(1, map[string]int64{"a": 1, "b": 1})}
+	storageURI, _ := url.Parse("s3://bucket/")
+	ctrl := &WalkController{}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err := walker.Walk(ctx, storageURI, WalkOptions{Controller: ctrl}, func(e ObjectStoreEntry) error {
+		ctrl.Pause()
+		cancel()
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("walk err=%v, expected context canceled while paused", err)
+	}
+	if !ctrl.Paused() {
+		t.Fatal("expected controller to stay paused")
+	}
+	if mark := walker.Marker(); mark.LastKey != "a" || !mark.HasMore {
+		t.Fatalf("mark=%+v, expected to resume after 'a'", mark)
+	}
+}
@@ -0,0 +1,57 @@
+package store
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// cursorVersion is the current cursor encoding version, bump on incompatible changes to walkCursor
+const cursorVersion = 1
+
+var (
+	ErrInvalidCursor            = errors.New("invalid walk cursor")
+	ErrUnsupportedCursorVersion = errors.New("unsupported walk cursor version")
+)
+
+// walkCursor holds the WalkOptions fields that describe a walk position
+type walkCursor struct {
+	Version           int    `json:"v"`
+	After             string `json:"a,omitempty"`
+	ContinuationToken string `json:"c,omitempty"`
+}
+
+// EncodeCursor encodes the position fields of op (After and ContinuationToken) into an opaque string that can be
+// persisted and later passed to DecodeCursor to resume the walk.
+func EncodeCursor(op WalkOptions) (string, error) {
+	data, err := json.Marshal(walkCursor{
+		Version:           cursorVersion,
+		After:             op.After,
+		ContinuationToken: op.ContinuationToken,
+	})
+	if err != nil {
+		return "", fmt.Errorf("encode cursor: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// DecodeCursor returns WalkOptions positioned at the cursor created by EncodeCursor.
+// Fails with ErrUnsupportedCursorVersion for cursors encoded by an incompatible version.
+func DecodeCursor(s string) (WalkOptions, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return WalkOptions{}, fmt.Errorf("%w: %s", ErrInvalidCursor, err)
+	}
+	var cursor walkCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return WalkOptions{}, fmt.Errorf("%w: %s", ErrInvalidCursor, err)
+	}
+	if cursor.Version != cursorVersion {
+		return WalkOptions{}, fmt.Errorf("%w: %d", ErrUnsupportedCursorVersion, cursor.Version)
+	}
+	return WalkOptions{
+		After:             cursor.After,
+		ContinuationToken: cursor.ContinuationToken,
+	}, nil
+}
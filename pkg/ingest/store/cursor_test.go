@@ -0,0 +1,74 @@
+package store_test
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/treeverse/lakefs/pkg/ingest/store"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	cases := []struct {
+		name string
+		op   store.WalkOptions
+	}{
+		{name: "empty", op: store.WalkOptions{}},
+		{name: "after", op: store.WalkOptions{After: "path/to/key"}},
+		{name: "token", op: store.WalkOptions{ContinuationToken: "opaque+token/=="}},
+		{name: "both", op: store.WalkOptions{After: "päth/ключ", ContinuationToken: "2!00000"}},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			cursor, err := store.EncodeCursor(tt.op)
+			if err != nil {
+				t.Fatalf("EncodeCursor(%+v): %s", tt.op, err)
+			}
+			op, err := store.DecodeCursor(cursor)
+			if err != nil {
+				t.Fatalf("DecodeCursor(%s): %s", cursor, err)
+			}
+			if op.After != tt.op.After || op.ContinuationToken != tt.op.ContinuationToken {
+				t.Fatalf("DecodeCursor(%s)=%+v, expected %+v", cursor, op, tt.op)
+			}
+		})
+	}
+
+	t.Run("position_only", func(t *testing.T) {
+		cursor, err := store.EncodeCursor(store.WalkOptions{After: "key", MaxBytes: 10})
+		if err != nil {
+			t.Fatalf("EncodeCursor: %s", err)
+		}
+		op, err := store.DecodeCursor(cursor)
+		if err != nil {
+			t.Fatalf("DecodeCursor: %s", err)
+		}
+		if op.After != "key" || op.MaxBytes != 0 {
+			t.Fatalf("DecodeCursor=%+v, expected only the position to be kept", op)
+		}
+	})
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	encode := func(s string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(s))
+	}
+	cases := []struct {
+		name   string
+		cursor string
+		err    error
+	}{
+		{name: "not_base64", cursor: "not a cursor!", err: store.ErrInvalidCursor},
+		{name: "not_json", cursor: encode("after=key"), err: store.ErrInvalidCursor},
+		{name: "no_version", cursor: encode(`{"a":"key"}`), err: store.ErrUnsupportedCursorVersion},
+		{name: "future_version", cursor: encode(`{"v":99,"a":"key"}`), err: store.ErrUnsupportedCursorVersion},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := store.DecodeCursor(tt.cursor)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("DecodeCursor(%s) err=%v, expected %s", tt.cursor, err, tt.err)
+			}
+		})
+	}
+}
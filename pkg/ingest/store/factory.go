@@ -5,17 +5,24 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"strings"
+	"sync"
 	"time"
 
 	"cloud.google.com/go/storage"
 	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/azblob"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/treeverse/lakefs/pkg/block"
+	"github.com/treeverse/lakefs/pkg/block/azure"
 	"github.com/treeverse/lakefs/pkg/block/factory"
 	"github.com/treeverse/lakefs/pkg/block/params"
 )
 
 var (
-	ErrNotSupported = errors.New("no storage adapter found")
+	ErrNotSupported            = errors.New("no storage adapter found")
+	ErrInvalidWalkerFactory    = errors.New("invalid walker factory")
+	ErrWalkerAlreadyRegistered = errors.New("walker already registered")
 )
 
 type ObjectStoreEntry struct {
@@ -23,17 +30,61 @@ type ObjectStoreEntry struct {
 	FullKey string
 	// RelativeKey represents a path relative to prefix (or directory). If none specified, will be identical to FullKey
 	RelativeKey string
-	// Address is a full URI for the entry, including the storage namespace (i.e. s3://bucket/path/to/key)
+	// Address is a full URI for the entry, including the storage namespace (i.e. s3://bucket/path/to/key).
+	// Holds only the key when walking with AddressStyleKeyOnly.
 	Address string
+	// PhysicalAddress is the address of the entry as lakeFS stores it (see CanonicalAddress), regardless of the
+	// AddressStyle and of signing the Address. Archive members use the archive address with the member name as
+	// its fragment.
+	PhysicalAddress string
 	// ETag represents a hash of the entry's content. Generally as hex encoded MD5,
-	// but depends on the underlying object store
+	// but depends on the underlying object store. Normalized by normalizeETag.
 	ETag string
+	// RawETag is the ETag as listed by the object store, set only when requested by WalkOptions.IncludeRawETag
+	RawETag string
 	// Mtime is the last-modified datetime of the entry
 	Mtime time.Time
 	// Size in bytes
 	Size int64
+	// CRC32C is the Castagnoli CRC32 of the entry's content, set only when requested by WalkOptions.ComputeCRC32C
+	CRC32C uint32
+	// SHA256 is the hex encoded SHA256 of the entry's content, set only for entries smaller than
+	// WalkOptions.HashBelowBytes
+	SHA256 string
+	// CopySource is the URL of the object this entry was copied from, set only when requested by
+	// WalkOptions.IncludeCopySource and the entry was created by a server side copy (Azure only)
+	CopySource string
+	// BlobType is the Azure blob type (BlockBlob, AppendBlob or PageBlob), empty for other object stores
+	BlobType string
+	// EncryptionScope is the Azure encryption scope the blob is encrypted under, empty for the account default
+	// encryption and for other object stores
+	EncryptionScope string
+	// CustomerProvidedKey is set for Azure blobs encrypted with a key provided by the client writing them. Whether
+	// an encryption scope uses a customer-managed key is a property of the scope, not reported per blob.
+	CustomerProvidedKey bool
+	// Owner, Group, Permissions and ACL are the POSIX style access control of the entry, set only when requested
+	// by WalkOptions.IncludeAccessControl and walking an Azure account with hierarchical namespace (ADLS Gen2)
+	Owner       string
+	Group       string
+	Permissions string
+	ACL         string
+	// ReplicationStatus is the Azure object replication status of the entry, ReplicationStatusComplete once
+	// replicated by all its rules. Set only when requested by WalkOptions.IncludeReplicationStatus, empty for
+	// objects without replication rules.
+	ReplicationStatus string
 }
 
+// AddressStyle controls the form of the walked entries Address
+type AddressStyle int
+
+const (
+	// AddressStyleFullURL addresses entries with a full URI, including the storage namespace
+	AddressStyleFullURL AddressStyle = iota
+	// AddressStyleKeyOnly addresses entries using only their key. The address is storage agnostic and can't be
+	// resolved without knowing the storage namespace it was walked from.
+	AddressStyleKeyOnly
+)
+
 type WalkOptions struct {
 	// All walked items must be greater then After
 	After string
@@ -41,12 +92,201 @@ type WalkOptions struct {
 	// ContinuationToken is passed to the client for efficient listing.
 	// Value is Opaque to the caller.
 	ContinuationToken string
+
+	// MaxBytes stops the walk once the sum of walked entries size reaches it, leaving Marker().HasMore set
+	// so the walk can be resumed from the marker. Zero means unlimited.
+	MaxBytes int64
+
+	// ComputeCRC32C sets the CRC32C of each walked entry. GCS provides the checksum as part of the listing, other
+	// object stores require reading the content of every object, which makes the walk considerably slower and
+	// costlier.
+	ComputeCRC32C bool
+
+	// HashBelowBytes sets the SHA256 of walked entries smaller than it, reading their content. Zero hashes no
+	// entries. Larger entries are left for a separate hashing stage. Entries are read using up to Concurrency reads
+	// in parallel, except by the GCS walker which reads them one at a time.
+	HashBelowBytes int64
+
+	// Concurrency is the number of objects read in parallel when the walk requires reading objects content.
+	// Zero or one reads a single object at a time.
+	Concurrency int
+
+	// IncludeRawETag sets the entries RawETag, the ETag before normalization. GCS entries ETag is the hex encoded
+	// MD5 of the content, their RawETag is the GCS object ETag.
+	IncludeRawETag bool
+
+	// IncludeCopySource sets the entries CopySource, supported only by the Azure walker
+	IncludeCopySource bool
+
+	// IncludeAccessControl sets the entries access control fields when walking an Azure account with hierarchical
+	// namespace. Requires an additional call per entry, made using up to Concurrency calls in parallel.
+	IncludeAccessControl bool
+
+	// IncludeReplicationStatus sets the entries ReplicationStatus, supported only by the Azure walker. Requires an
+	// additional call per entry, made using up to Concurrency calls in parallel.
+	IncludeReplicationStatus bool
+
+	// SkipIncompleteReplication skips entries with replication rules that are not yet complete, counting them by
+	// Marker().Skipped. Implies IncludeReplicationStatus.
+	SkipIncompleteReplication bool
+
+	// EncryptionScopes limits the walk to Azure blobs encrypted under one of the given scopes, empty walks blobs of
+	// all scopes. Blobs of other scopes are skipped and counted by Marker().Skipped.
+	EncryptionScopes []string
+
+	// RequireCustomerProvidedKey limits the walk to Azure blobs encrypted with a customer-provided key. Other blobs
+	// are skipped and counted by Marker().Skipped.
+	RequireCustomerProvidedKey bool
+
+	// ExactMatch walks only the blob named by the storage URI path, reading its properties instead of listing blobs
+	// sharing the path as a prefix. Nothing is walked when the blob doesn't exist. Supported only by the Azure walker.
+	ExactMatch bool
+
+	// BlobTypes limits the walk to Azure blobs of the given types (i.e. BlockBlob), empty walks all types
+	BlobTypes []string
+
+	// AddressStyle of the walked entries Address, defaults to AddressStyleFullURL
+	AddressStyle AddressStyle
+
+	// Controller, when set, can pause and resume the walk between list calls
+	Controller *WalkController
+
+	// Throttle, when set, limits the rate of requests sent by the walk, backing off when the service throttles
+	// requests (429 or 503 responses). Supported by the Azure walker.
+	Throttle *AdaptiveThrottle
+
+	// PageTimeout bounds the time spent listing, reading and emitting the entries of a single page, failing the
+	// walk with ErrPageTimeout when exceeded. Zero means no timeout. Not supported by the GCS walker, which lists
+	// pages internally.
+	PageTimeout time.Duration
+
+	// MaxListRestarts is the number of times the Azure walker lists again when the service rejects an expired
+	// continuation token. Listing can't start after a key, so listing again starts over from the beginning of the
+	// prefix and re-lists every page already walked, the After filter skipping their entries. Each restart costs as
+	// many list calls as the walk made so far. Zero fails the walk on an expired token.
+	MaxListRestarts int
+
+	// PageBoundaryMark advances Marker() only once all the entries of a listed page were walked, setting together
+	// the last key listed in the page and the token of the next page. Resuming from the mark lists the following
+	// page exactly, without repeating or missing entries. MaxBytes is checked between pages, so a walk may exceed it
+	// by up to a page. Supported by the Azure walker.
+	PageBoundaryMark bool
+
+	// EstimatePages is the number of pages listed by Estimate to sample the walk, defaults to
+	// DefaultEstimatePages. Supported by the Azure walker.
+	EstimatePages int
+
+	// TargetPrefix is prepended to the RelativeKey of each walked entry, joined by a single path delimiter.
+	// FullKey and Address keep addressing the source entry.
+	TargetPrefix string
+
+	// Skip, when set, is called with each listed entry before it is walked, entries it returns true for are not
+	// passed to walkFn and are counted by Marker().Skipped. It is called before reading the entry content or
+	// fetching additional metadata, so only the listed fields are set.
+	Skip func(entry ObjectStoreEntry) bool
+}
+
+// skip reports if the walk Skip predicate excludes ent
+func (op WalkOptions) skip(ent ObjectStoreEntry) bool {
+	return op.Skip != nil && op.Skip(ent)
+}
+
+// normalizeETag returns etag in its canonical form: without a weak validator prefix (W/) and without the
+// surrounding quotes. The ETag value itself is kept as is, including its case.
+func normalizeETag(etag string) string {
+	etag = strings.TrimSpace(etag)
+	etag = strings.TrimPrefix(etag, "W/")
+	return strings.Trim(etag, `"`)
+}
+
+// CanonicalAddress returns the physical address of key as lakeFS stores it: the scheme of storageType followed by
+// the namespace (i.e. the bucket) and the key
+func CanonicalAddress(storageType block.StorageType, namespace, key string) string {
+	return block.QualifiedKey{
+		StorageType:      storageType,
+		StorageNamespace: namespace,
+		Key:              key,
+	}.Format()
+}
+
+// relativeKey returns key relative to the walked base path, prefixed by the walk TargetPrefix
+func (op WalkOptions) relativeKey(key, basePath string) string {
+	relative := strings.TrimPrefix(key, basePath)
+	if op.TargetPrefix == "" {
+		return relative
+	}
+	return strings.TrimSuffix(op.TargetPrefix, "/") + "/" + strings.TrimPrefix(relative, "/")
+}
+
+// CompletionReason is why a walk ended
+type CompletionReason int
+
+const (
+	// CompletionReasonNone is set until the walk ends
+	CompletionReasonNone CompletionReason = iota
+	// CompletionReasonCompleted is set when all the entries were walked
+	CompletionReasonCompleted
+	// CompletionReasonLimitReached is set when the walk stopped on a WalkOptions limit (i.e. MaxBytes), it can be
+	// resumed from the mark
+	CompletionReasonLimitReached
+	// CompletionReasonCancelled is set when the walk context was cancelled or its deadline exceeded
+	CompletionReasonCancelled
+	// CompletionReasonError is set when the walk failed listing the entries or walkFn returned an error
+	CompletionReasonError
+)
+
+func (r CompletionReason) String() string {
+	switch r {
+	case CompletionReasonNone:
+		return "none"
+	case CompletionReasonCompleted:
+		return "completed"
+	case CompletionReasonLimitReached:
+		return "limit reached"
+	case CompletionReasonCancelled:
+		return "cancelled"
+	case CompletionReasonError:
+		return "error"
+	default:
+		return fmt.Sprintf("CompletionReason(%d)", int(r))
+	}
 }
 
 type Mark struct {
 	ContinuationToken string
 	LastKey           string
 	HasMore           bool
+	// Reason is why the last walk ended
+	Reason CompletionReason
+	// Skipped is the number of entries excluded during the last walk by the WalkOptions Skip predicate and the
+	// blob type, replication and encryption filters. Entries listed after the walk stopped are not counted.
+	Skipped int64
+}
+
+// errWalkLimitReached is returned by walkers internally to stop the walk once it reached a WalkOptions limit
+var errWalkLimitReached = errors.New("walk limit reached")
+
+// startWalk resets the mark fields describing the last walk
+func startWalk(mark *Mark) {
+	mark.Reason = CompletionReasonNone
+	mark.Skipped = 0
+}
+
+// endWalk sets the mark completion reason based on the error the walk ended with, and returns the error the
+// walk should return
+func endWalk(ctx context.Context, mark *Mark, err error) error {
+	switch {
+	case err == nil:
+		mark.Reason = CompletionReasonCompleted
+	case errors.Is(err, errWalkLimitReached):
+		mark.Reason = CompletionReasonLimitReached
+		return nil
+	case ctx.Err() != nil, errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		mark.Reason = CompletionReasonCancelled
+	default:
+		mark.Reason = CompletionReasonError
+	}
+	return err
 }
 
 type Walker interface {
@@ -62,6 +302,9 @@ func (e ObjectStoreEntry) String() string {
 type WalkerOptions struct {
 	S3EndpointURL string
 	StorageURI    string
+	// AzureUserDelegation authorizes Azure requests with user delegation SAS minted using AAD (managed identity)
+	// credentials, instead of using the credentials directly
+	AzureUserDelegation *AzureUserDelegationOptions
 }
 
 type WalkerWrapper struct {
@@ -84,6 +327,48 @@ func (ww *WalkerWrapper) Marker() Mark {
 	return ww.walker.Marker()
 }
 
+// WalkerFactory builds a Walker for a storage URI scheme. params is the adapter configuration the factory was
+// created with and may be nil, in which case the walker should fall back to environment based configuration.
+type WalkerFactory func(ctx context.Context, params params.AdapterConfig, opts WalkerOptions) (Walker, error)
+
+// map walker factories by storage URI scheme
+var (
+	walkerFactories   = make(map[string]WalkerFactory)
+	walkerFactoriesMu sync.RWMutex
+)
+
+//nolint:gochecknoinits
+func init() {
+	mustRegisterWalker("s3", buildS3Walker)
+	mustRegisterWalker("gs", buildGCSWalker)
+	mustRegisterWalker("http", buildAzureWalker)
+	mustRegisterWalker("https", buildAzureWalker)
+}
+
+// RegisterWalker registers 'factory' to build walkers for storage URIs using 'scheme'.
+// Fails with ErrWalkerAlreadyRegistered in case the scheme already has a factory.
+func RegisterWalker(scheme string, factory WalkerFactory) error {
+	if scheme == "" {
+		return fmt.Errorf("%w: missing scheme", ErrInvalidWalkerFactory)
+	}
+	if factory == nil {
+		return fmt.Errorf("%w: nil factory for scheme %s", ErrInvalidWalkerFactory, scheme)
+	}
+	walkerFactoriesMu.Lock()
+	defer walkerFactoriesMu.Unlock()
+	if _, found := walkerFactories[scheme]; found {
+		return fmt.Errorf("%w: %s", ErrWalkerAlreadyRegistered, scheme)
+	}
+	walkerFactories[scheme] = factory
+	return nil
+}
+
+func mustRegisterWalker(scheme string, factory WalkerFactory) {
+	if err := RegisterWalker(scheme, factory); err != nil {
+		panic(err)
+	}
+}
+
 type walkerFactory struct {
 	params params.AdapterConfig
 }
@@ -92,10 +377,10 @@ func NewFactory(params params.AdapterConfig) *walkerFactory {
 	return &walkerFactory{params: params}
 }
 
-func (f *walkerFactory) buildS3Walker(opts WalkerOptions) (*s3Walker, error) {
+func buildS3Walker(_ context.Context, params params.AdapterConfig, opts WalkerOptions) (Walker, error) {
 	var sess *session.Session
-	if f.params != nil {
-		s3params, err := f.params.GetBlockAdapterS3Params()
+	if params != nil {
+		s3params, err := params.GetBlockAdapterS3Params()
 		if err != nil {
 			return nil, err
 		}
@@ -113,10 +398,10 @@ func (f *walkerFactory) buildS3Walker(opts WalkerOptions) (*s3Walker, error) {
 	return NewS3Walker(sess), nil
 }
 
-func (f *walkerFactory) buildGCSWalker(ctx context.Context) (*gcsWalker, error) {
+func buildGCSWalker(ctx context.Context, params params.AdapterConfig, _ WalkerOptions) (Walker, error) {
 	var svc *storage.Client
-	if f.params != nil {
-		gsParams, err := f.params.GetBlockAdapterGSParams()
+	if params != nil {
+		gsParams, err := params.GetBlockAdapterGSParams()
 		if err != nil {
 			return nil, err
 		}
@@ -134,10 +419,13 @@ func (f *walkerFactory) buildGCSWalker(ctx context.Context) (*gcsWalker, error)
 	return NewGCSWalker(svc), nil
 }
 
-func (f *walkerFactory) buildAzureWalker() (*azureBlobWalker, error) {
+func buildAzureWalker(_ context.Context, params params.AdapterConfig, opts WalkerOptions) (Walker, error) {
+	if opts.AzureUserDelegation != nil {
+		return buildAzureUserDelegationWalker(params, opts)
+	}
 	var p pipeline.Pipeline
-	if f.params != nil {
-		azureParams, err := f.params.GetBlockAdapterAzureParams()
+	if params != nil {
+		azureParams, err := params.GetBlockAdapterAzureParams()
 		if err != nil {
 			return nil, err
 		}
@@ -155,31 +443,44 @@ func (f *walkerFactory) buildAzureWalker() (*azureBlobWalker, error) {
 	return NewAzureBlobWalker(p)
 }
 
+func buildAzureUserDelegationWalker(params params.AdapterConfig, opts WalkerOptions) (Walker, error) {
+	storageURI, err := url.Parse(opts.StorageURI)
+	if err != nil {
+		return nil, err
+	}
+	var tryTimeout time.Duration
+	if params != nil {
+		azureParams, err := params.GetBlockAdapterAzureParams()
+		if err != nil {
+			return nil, err
+		}
+		tryTimeout = azureParams.TryTimeout
+	}
+	credential, err := azure.GetMSICredentials()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrAzureCredentials, err)
+	}
+	pipelineOptions := azblob.PipelineOptions{Retry: azblob.RetryOptions{TryTimeout: tryTimeout}}
+	serviceURL := url.URL{Scheme: storageURI.Scheme, Host: storageURI.Host}
+	provider := azblob.NewServiceURL(serviceURL, azblob.NewPipeline(credential, pipelineOptions))
+	p := azblob.NewPipeline(azblob.NewAnonymousCredential(), pipelineOptions)
+	return NewAzureBlobWalkerWithUserDelegation(p, provider, *opts.AzureUserDelegation), nil
+}
+
 func (f *walkerFactory) GetWalker(ctx context.Context, opts WalkerOptions) (*WalkerWrapper, error) {
-	var walker Walker
 	uri, err := url.Parse(opts.StorageURI)
 	if err != nil {
 		return nil, fmt.Errorf("could not parse storage URI %s: %w", uri, err)
 	}
-	switch uri.Scheme {
-	case "s3":
-		walker, err = f.buildS3Walker(opts)
-		if err != nil {
-			return nil, fmt.Errorf("creating s3 walker: %w", err)
-		}
-	case "gs":
-		walker, err = f.buildGCSWalker(ctx)
-		if err != nil {
-			return nil, fmt.Errorf("creating gs walker: %w", err)
-		}
-	case "http", "https":
-		walker, err = f.buildAzureWalker()
-		if err != nil {
-			return nil, fmt.Errorf("creating Azure walker: %w", err)
-		}
-	default:
+	walkerFactoriesMu.RLock()
+	build, ok := walkerFactories[uri.Scheme]
+	walkerFactoriesMu.RUnlock()
+	if !ok {
 		return nil, fmt.Errorf("%w: for scheme: %s", ErrNotSupported, uri.Scheme)
 	}
-
+	walker, err := build(ctx, f.params, opts)
+	if err != nil {
+		return nil, fmt.Errorf("creating %s walker: %w", uri.Scheme, err)
+	}
 	return NewWrapper(walker, uri), nil
 }
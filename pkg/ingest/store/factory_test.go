@@ -0,0 +1,93 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/treeverse/lakefs/pkg/block/params"
+	"github.com/treeverse/lakefs/pkg/ingest/store"
+)
+
+type fakeWalker struct {
+	opts store.WalkerOptions
+}
+
+func (w *fakeWalker) Walk(_ context.Context, _ *url.URL, _ store.WalkOptions, walkFn func(e store.ObjectStoreEntry) error) error {
+	return walkFn(store.ObjectStoreEntry{FullKey: "key", RelativeKey: "key", Address: w.opts.StorageURI + "/key"})
+}
+
+func (w *fakeWalker) Marker() store.Mark {
+	return store.Mark{}
+}
+
+func TestRegisterWalker(t *testing.T) {
+	ctx := context.Background()
+	err := store.RegisterWalker("fake", func(_ context.Context, _ params.AdapterConfig, opts store.WalkerOptions) (store.Walker, error) {
+		return &fakeWalker{opts: opts}, nil
+	})
+	if err != nil {
+		t.Fatalf("RegisterWalker 'fake': %s", err)
+	}
+
+	t.Run("custom", func(t *testing.T) {
+		const storageURI = "fake://bucket/prefix"
+		walker, err := store.NewFactory(nil).GetWalker(ctx, store.WalkerOptions{StorageURI: storageURI})
+		if err != nil {
+			t.Fatalf("GetWalker '%s': %s", storageURI, err)
+		}
+		var entries []store.ObjectStoreEntry
+		err = walker.Walk(ctx, store.WalkOptions{}, func(e store.ObjectStoreEntry) error {
+			entries = append(entries, e)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Walk: %s", err)
+		}
+		if len(entries) != 1 || entries[0].Address != storageURI+"/key" {
+			t.Fatalf("Walk entries=%v, expected a single entry from the fake walker", entries)
+		}
+	})
+
+	t.Run("duplicate", func(t *testing.T) {
+		err := store.RegisterWalker("fake", func(_ context.Context, _ params.AdapterConfig, _ store.WalkerOptions) (store.Walker, error) {
+			return &fakeWalker{}, nil
+		})
+		if !errors.Is(err, store.ErrWalkerAlreadyRegistered) {
+			t.Fatalf("RegisterWalker duplicate err=%v, expected %s", err, store.ErrWalkerAlreadyRegistered)
+		}
+	})
+
+	t.Run("builtin", func(t *testing.T) {
+		err := store.RegisterWalker("s3", func(_ context.Context, _ params.AdapterConfig, _ store.WalkerOptions) (store.Walker, error) {
+			return &fakeWalker{}, nil
+		})
+		if !errors.Is(err, store.ErrWalkerAlreadyRegistered) {
+			t.Fatalf("RegisterWalker 's3' err=%v, expected %s", err, store.ErrWalkerAlreadyRegistered)
+		}
+		walker, err := store.NewFactory(nil).GetWalker(ctx, store.WalkerOptions{StorageURI: "s3://bucket/prefix"})
+		if err != nil {
+			t.Fatalf("GetWalker s3: %s", err)
+		}
+		if walker == nil {
+			t.Fatal("GetWalker s3 returned nil walker")
+		}
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		_, err := store.NewFactory(nil).GetWalker(ctx, store.WalkerOptions{StorageURI: "unknown://bucket"})
+		if !errors.Is(err, store.ErrNotSupported) {
+			t.Fatalf("GetWalker unknown scheme err=%v, expected %s", err, store.ErrNotSupported)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		if err := store.RegisterWalker("", nil); !errors.Is(err, store.ErrInvalidWalkerFactory) {
+			t.Fatalf("RegisterWalker no scheme err=%v, expected %s", err, store.ErrInvalidWalkerFactory)
+		}
+		if err := store.RegisterWalker("nil", nil); !errors.Is(err, store.ErrInvalidWalkerFactory) {
+			t.Fatalf("RegisterWalker nil factory err=%v, expected %s", err, store.ErrInvalidWalkerFactory)
+		}
+	})
+}
@@ -5,10 +5,12 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
 	"net/url"
 	"strings"
 
 	"cloud.google.com/go/storage"
+	"github.com/treeverse/lakefs/pkg/block"
 	"google.golang.org/api/iterator"
 )
 
@@ -22,6 +24,12 @@ func NewGCSWalker(client *storage.Client) *gcsWalker {
 }
 
 func (w *gcsWalker) Walk(ctx context.Context, storageURI *url.URL, op WalkOptions, walkFn func(e ObjectStoreEntry) error) error {
+	startWalk(&w.mark)
+	err := w.walk(ctx, storageURI, op, walkFn)
+	return endWalk(ctx, &w.mark, err)
+}
+
+func (w *gcsWalker) walk(ctx context.Context, storageURI *url.URL, op WalkOptions, walkFn func(e ObjectStoreEntry) error) error {
 	prefix := strings.TrimLeft(storageURI.Path, "/")
 	iter := w.client.
 		Bucket(storageURI.Host).
@@ -30,7 +38,14 @@ func (w *gcsWalker) Walk(ctx context.Context, storageURI *url.URL, op WalkOption
 			StartOffset: op.After,
 		})
 
+	var walkedBytes int64
 	for {
+		// the iterator lists the next page only when the current one is consumed
+		if iter.PageInfo().Remaining() == 0 {
+			if err := op.Controller.wait(ctx); err != nil {
+				return err
+			}
+		}
 		attrs, err := iter.Next()
 
 		if errors.Is(err, iterator.Done) {
@@ -45,29 +60,64 @@ func (w *gcsWalker) Walk(ctx context.Context, storageURI *url.URL, op WalkOption
 			continue
 		}
 
-		w.mark = Mark{
-			LastKey: attrs.Name,
-			HasMore: true,
+		if op.MaxBytes > 0 && walkedBytes >= op.MaxBytes {
+			// bytes budget reached - keep the current mark for resume
+			return errWalkLimitReached
+		}
+		ent := ObjectStoreEntry{
+			FullKey:         attrs.Name,
+			RelativeKey:     op.relativeKey(attrs.Name, prefix),
+			Address:         fmt.Sprintf("gs://%s/%s", attrs.Bucket, attrs.Name),
+			PhysicalAddress: CanonicalAddress(block.StorageTypeGS, attrs.Bucket, attrs.Name),
+			ETag:            hex.EncodeToString(attrs.MD5),
+			Mtime:           attrs.Updated,
+			Size:            attrs.Size,
+		}
+		if op.AddressStyle == AddressStyleKeyOnly {
+			ent.Address = attrs.Name
+		}
+		if op.IncludeRawETag {
+			ent.RawETag = attrs.Etag
+		}
+		if op.ComputeCRC32C {
+			// GCS computes crc32c for every object, no need to read the content
+			ent.CRC32C = attrs.CRC32C
+		}
+		if op.skip(ent) {
+			w.mark.Skipped++
+			continue
+		}
+		if op.HashBelowBytes > 0 {
+			// entries are listed one at a time, hashed without concurrency
+			entries := []ObjectStoreEntry{ent}
+			if err := computeSHA256(ctx, entries, op.HashBelowBytes, 1, w.openObject(attrs.Bucket)); err != nil {
+				return err
+			}
+			ent = entries[0]
 		}
-		if err := walkFn(ObjectStoreEntry{
-			FullKey:     attrs.Name,
-			RelativeKey: strings.TrimPrefix(attrs.Name, prefix),
-			Address:     fmt.Sprintf("gs://%s/%s", attrs.Bucket, attrs.Name),
-			ETag:        hex.EncodeToString(attrs.MD5),
-			Mtime:       attrs.Updated,
-			Size:        attrs.Size,
-		}); err != nil {
+		w.mark.LastKey = attrs.Name
+		w.mark.HasMore = true
+		if err := walkFn(ent); err != nil {
 			return err
 		}
+		walkedBytes += attrs.Size
 	}
-	w.mark = Mark{
-		LastKey: "",
-		HasMore: false,
-	}
+	w.mark.LastKey = ""
+	w.mark.HasMore = false
 
 	return nil
 }
 
+func (w *gcsWalker) openObject(bucket string) objectOpener {
+	return func(ctx context.Context, e ObjectStoreEntry) (io.ReadCloser, error) {
+		r, err := w.client.Bucket(bucket).Object(e.FullKey).NewReader(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("read object %s: %w", e.Address, err)
+		}
+		return r, nil
+	}
+}
+
 func (w *gcsWalker) Marker() Mark {
 	return w.mark
 }
@@ -0,0 +1,46 @@
+package store
+
+// Marks are ordered by LastKey, the last key walked. Keys are compared as byte strings, the same order object
+// stores list keys in, so resuming a walk with After set to LastKey walks every key after it. A Mark with HasMore
+// unset is of a completed walk and is ordered after any Mark that has more to walk, regardless of its LastKey.
+// A Mark with HasMore set and an empty LastKey is of a walk that didn't walk any key yet, and is ordered first.
+
+// CompareMarks returns -1 when a is before b, 1 when a is after b and 0 when both resume from the same position
+func CompareMarks(a, b Mark) int {
+	switch {
+	case a.HasMore != b.HasMore:
+		if a.HasMore {
+			return -1
+		}
+		return 1
+	case !a.HasMore || a.LastKey == b.LastKey:
+		return 0
+	case a.LastKey < b.LastKey:
+		return -1
+	default:
+		return 1
+	}
+}
+
+// MinMark returns the position a walk over all the shards the marks were saved by can safely resume from: the
+// first of the marks. Every key up to its LastKey was walked by all shards. The ContinuationToken is cleared, as
+// it is specific to the listing of a single shard, resume using After set to LastKey.
+// Returns a completed Mark, as walkers set it, when all marks are completed, and the start of a walk when no marks are passed.
+func MinMark(marks ...Mark) Mark {
+	if len(marks) == 0 {
+		return Mark{HasMore: true}
+	}
+	min := marks[0]
+	for _, m := range marks[1:] {
+		if CompareMarks(m, min) < 0 {
+			min = m
+		}
+	}
+	if !min.HasMore {
+		return Mark{}
+	}
+	return Mark{
+		LastKey: min.LastKey,
+		HasMore: min.HasMore,
+	}
+}
@@ -0,0 +1,73 @@
+package store
+
+import "testing"
+
+func TestCompareMarks(t *testing.T) {
+	tests := []struct {
+		name     string
+		a, b     Mark
+		expected int
+	}{
+		{name: "before", a: Mark{LastKey: "a", HasMore: true}, b: Mark{LastKey: "b", HasMore: true}, expected: -1},
+		{name: "after", a: Mark{LastKey: "b", HasMore: true}, b: Mark{LastKey: "a", HasMore: true}, expected: 1},
+		{name: "equal", a: Mark{LastKey: "a", HasMore: true, ContinuationToken: "1"}, b: Mark{LastKey: "a", HasMore: true}, expected: 0},
+		{name: "not_started", a: Mark{HasMore: true}, b: Mark{LastKey: "a", HasMore: true}, expected: -1},
+		{name: "completed", a: Mark{LastKey: "z"}, b: Mark{LastKey: "a", HasMore: true}, expected: 1},
+		{name: "both_completed", a: Mark{LastKey: "z"}, b: Mark{}, expected: 0},
+		{name: "byte_order", a: Mark{LastKey: "B", HasMore: true}, b: Mark{LastKey: "a", HasMore: true}, expected: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CompareMarks(tt.a, tt.b); got != tt.expected {
+				t.Fatalf("CompareMarks(%+v, %+v) = %d, expected %d", tt.a, tt.b, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMinMark(t *testing.T) {
+	tests := []struct {
+		name     string
+		marks    []Mark
+		expected Mark
+	}{
+		{name: "none", expected: Mark{HasMore: true}},
+		{
+			name: "shards",
+			marks: []Mark{
+				{LastKey: "shard2/obj05", HasMore: true, ContinuationToken: "token2"},
+				{LastKey: "shard1/obj09", HasMore: true, ContinuationToken: "token1"},
+				{LastKey: "shard3/obj01", HasMore: true},
+			},
+			expected: Mark{LastKey: "shard1/obj09", HasMore: true},
+		},
+		{
+			name: "completed_shards",
+			marks: []Mark{
+				{LastKey: "shard1/obj09", Reason: CompletionReasonCompleted},
+				{LastKey: "shard2/obj05", HasMore: true, Reason: CompletionReasonLimitReached},
+			},
+			expected: Mark{LastKey: "shard2/obj05", HasMore: true},
+		},
+		{
+			name: "not_started_shard",
+			marks: []Mark{
+				{LastKey: "shard1/obj09", HasMore: true},
+				{HasMore: true},
+			},
+			expected: Mark{HasMore: true},
+		},
+		{
+			name:     "all_completed",
+			marks:    []Mark{{LastKey: "shard1/obj09"}, {LastKey: "shard2/obj05"}},
+			expected: Mark{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MinMark(tt.marks...); got != tt.expected {
+				t.Fatalf("MinMark = %+v, expected %+v", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+var ErrPageTimeout = errors.New("walk page timeout")
+
+// pageContext is the context for fetching and emitting a single page, cancelled once the page timeout passes.
+// It is cancelled by a timer rather than given a deadline, as azblob derives its per try timeout from the context
+// deadline rounded down to whole seconds.
+type pageContext struct {
+	context.Context
+	cancel  context.CancelFunc
+	timer   *time.Timer
+	timeout time.Duration
+	expired int32
+}
+
+func newPageContext(ctx context.Context, timeout time.Duration) *pageContext {
+	pageCtx, cancel := context.WithCancel(ctx)
+	p := &pageContext{
+		Context: pageCtx,
+		cancel:  cancel,
+		timeout: timeout,
+	}
+	if timeout > 0 {
+		p.timer = time.AfterFunc(timeout, func() {
+			atomic.StoreInt32(&p.expired, 1)
+			cancel()
+		})
+	}
+	return p
+}
+
+// close releases the page context resources
+func (p *pageContext) close() {
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+	p.cancel()
+}
+
+// wrapErr returns an ErrPageTimeout error in case the page timeout passed, otherwise returns err
+func (p *pageContext) wrapErr(err error) error {
+	if err == nil || atomic.LoadInt32(&p.expired) == 0 {
+		return err
+	}
+	return fmt.Errorf("%w: exceeded %s: %s", ErrPageTimeout, p.timeout, err)
+}
+
+// listedPage holds the entries of a listed page to walk, and the number of entries skipped in between them. The
+// skipped entries are counted on the mark only once the walk reaches them.
+type listedPage struct {
+	entries []ObjectStoreEntry
+	// skipped[i] is the number of entries skipped before entries[i], the last one counts those after all entries
+	skipped []int64
+}
+
+func newListedPage(size int) *listedPage {
+	return &listedPage{
+		entries: make([]ObjectStoreEntry, 0, size),
+		skipped: make([]int64, 1, size+1),
+	}
+}
+
+// add appends ent to the entries to walk
+func (p *listedPage) add(ent ObjectStoreEntry) {
+	p.entries = append(p.entries, ent)
+	p.skipped = append(p.skipped, 0)
+}
+
+// skip counts an entry skipped after the last added entry
+func (p *listedPage) skip() {
+	p.skipped[len(p.skipped)-1]++
+}
+
+// filter skips the entries keep returns false for
+func (p *listedPage) filter(keep func(ent ObjectStoreEntry) bool) {
+	entries, skipped := p.entries[:0], p.skipped[:1]
+	for i, ent := range p.entries {
+		if !keep(ent) {
+			skipped[len(skipped)-1] += 1 + p.skipped[i+1]
+			continue
+		}
+		entries = append(entries, ent)
+		skipped = append(skipped, p.skipped[i+1])
+	}
+	p.entries, p.skipped = entries, skipped
+}
+
+// countSkipped counts on mark the entries skipped before entries[i], once the walk reaches entries[i]. Passing
+// len(entries) counts the entries skipped after all the entries.
+func (p *listedPage) countSkipped(i int, mark *Mark) {
+	if n := p.skipped[i]; n > 0 {
+		mark.Skipped += n
+	}
+}
+
+// withinBudget trims the page to the leading entries walked before maxBytes is reached, starting with walkedBytes
+// already walked, and reports if the budget is reached within the page. Entries skipped after the budget is reached
+// are not counted. Zero maxBytes walks all the entries.
+func (p *listedPage) withinBudget(maxBytes, walkedBytes int64) bool {
+	if maxBytes <= 0 {
+		return false
+	}
+	for i, ent := range p.entries {
+		if walkedBytes >= maxBytes {
+			p.entries = p.entries[:i]
+			p.skipped = p.skipped[:i+1]
+			p.skipped[i] = 0
+			return true
+		}
+		walkedBytes += ent.Size
+	}
+	if walkedBytes >= maxBytes && p.skipped[len(p.entries)] > 0 {
+		// the budget is reached before the entries skipped after the last entry
+		p.skipped[len(p.entries)] = 0
+		return true
+	}
+	return false
+}
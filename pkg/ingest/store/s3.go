@@ -3,6 +3,7 @@ package store
 import (
 	"context"
 	"fmt"
+	"io"
 	"net/url"
 	"strings"
 
@@ -10,6 +11,7 @@ import (
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/treeverse/lakefs/pkg/block"
 )
 
 func getS3Client(s3EndpointURL string) (*session.Session, error) {
@@ -40,8 +42,13 @@ func NewS3Walker(sess *session.Session) *s3Walker {
 }
 
 func (s *s3Walker) Walk(ctx context.Context, storageURI *url.URL, op WalkOptions, walkFn func(e ObjectStoreEntry) error) error {
+	startWalk(&s.mark)
+	err := s.walk(ctx, storageURI, op, walkFn)
+	return endWalk(ctx, &s.mark, err)
+}
+
+func (s *s3Walker) walk(ctx context.Context, storageURI *url.URL, op WalkOptions, walkFn func(e ObjectStoreEntry) error) error {
 	var continuation *string
-	const maxKeys = 1000
 	prefix := strings.TrimLeft(storageURI.Path, "/")
 
 	// basePath is the path relative to which the walk is done. The key of the resulting entries will be relative to this path.
@@ -61,49 +68,110 @@ func (s *s3Walker) Walk(ctx context.Context, storageURI *url.URL, op WalkOptions
 		basePath = prefix[:idx+1]
 	}
 	bucket := storageURI.Host
+	var walkedBytes int64
 	for {
-		result, err := s.s3.ListObjectsV2WithContext(ctx, &s3.ListObjectsV2Input{
-			Bucket:            aws.String(bucket),
-			ContinuationToken: continuation,
-			MaxKeys:           aws.Int64(maxKeys),
-			Prefix:            aws.String(prefix),
-			StartAfter:        aws.String(op.After),
-		})
-		if err != nil {
+		if err := op.Controller.wait(ctx); err != nil {
 			return err
 		}
-		for _, record := range result.Contents {
-			key := aws.StringValue(record.Key)
-			addr := fmt.Sprintf("s3://%s/%s", bucket, key)
-			ent := ObjectStoreEntry{
-				FullKey:     key,
-				RelativeKey: strings.TrimPrefix(key, basePath),
-				Address:     addr,
-				ETag:        strings.Trim(aws.StringValue(record.ETag), "\""),
-				Mtime:       aws.TimeValue(record.LastModified),
-				Size:        aws.Int64Value(record.Size),
-			}
-			s.mark = Mark{
-				LastKey: key,
-				HasMore: true,
-			}
-			err := walkFn(ent)
-			if err != nil {
-				return err
-			}
+		result, err := s.walkPage(ctx, bucket, prefix, basePath, continuation, op, &walkedBytes, walkFn)
+		if err != nil {
+			return err
 		}
 		if !aws.BoolValue(result.IsTruncated) {
 			break
 		}
 		continuation = result.NextContinuationToken
 	}
-	s.mark = Mark{
-		LastKey: "",
-		HasMore: false,
-	}
+	s.mark.LastKey = ""
+	s.mark.HasMore = false
 	return nil
 }
 
+// walkPage lists a single page of objects starting at continuation and passes its entries to walkFn
+func (s *s3Walker) walkPage(ctx context.Context, bucket, prefix, basePath string, continuation *string, op WalkOptions, walkedBytes *int64, walkFn func(e ObjectStoreEntry) error) (*s3.ListObjectsV2Output, error) {
+	const maxKeys = 1000
+	pageCtx := newPageContext(ctx, op.PageTimeout)
+	defer pageCtx.close()
+	result, err := s.s3.ListObjectsV2WithContext(pageCtx, &s3.ListObjectsV2Input{
+		Bucket:            aws.String(bucket),
+		ContinuationToken: continuation,
+		MaxKeys:           aws.Int64(maxKeys),
+		Prefix:            aws.String(prefix),
+		StartAfter:        aws.String(op.After),
+	})
+	if err != nil {
+		return nil, pageCtx.wrapErr(err)
+	}
+	page := newListedPage(len(result.Contents))
+	for _, record := range result.Contents {
+		key := aws.StringValue(record.Key)
+		ent := ObjectStoreEntry{
+			FullKey:         key,
+			RelativeKey:     op.relativeKey(key, basePath),
+			Address:         fmt.Sprintf("s3://%s/%s", bucket, key),
+			PhysicalAddress: CanonicalAddress(block.StorageTypeS3, bucket, key),
+			ETag:            normalizeETag(aws.StringValue(record.ETag)),
+			Mtime:           aws.TimeValue(record.LastModified),
+			Size:            aws.Int64Value(record.Size),
+		}
+		if op.AddressStyle == AddressStyleKeyOnly {
+			ent.Address = key
+		}
+		if op.IncludeRawETag {
+			ent.RawETag = aws.StringValue(record.ETag)
+		}
+		if op.skip(ent) {
+			page.skip()
+			continue
+		}
+		page.add(ent)
+	}
+	// read the content only of the entries walked within the bytes budget
+	limited := page.withinBudget(op.MaxBytes, *walkedBytes)
+	if op.ComputeCRC32C {
+		if err := computeCRC32C(pageCtx, page.entries, op.Concurrency, s.openObject(bucket)); err != nil {
+			return nil, pageCtx.wrapErr(err)
+		}
+	}
+	if op.HashBelowBytes > 0 {
+		if err := computeSHA256(pageCtx, page.entries, op.HashBelowBytes, op.Concurrency, s.openObject(bucket)); err != nil {
+			return nil, pageCtx.wrapErr(err)
+		}
+	}
+	for i, ent := range page.entries {
+		page.countSkipped(i, &s.mark)
+		if err := pageCtx.Err(); err != nil {
+			return nil, pageCtx.wrapErr(err)
+		}
+		s.mark.LastKey = ent.FullKey
+		s.mark.HasMore = true
+		err := walkFn(ent)
+		if err != nil {
+			return nil, err
+		}
+		*walkedBytes += ent.Size
+	}
+	page.countSkipped(len(page.entries), &s.mark)
+	if limited {
+		// bytes budget reached - keep the current mark for resume
+		return nil, errWalkLimitReached
+	}
+	return result, nil
+}
+
+func (s *s3Walker) openObject(bucket string) objectOpener {
+	return func(ctx context.Context, e ObjectStoreEntry) (io.ReadCloser, error) {
+		obj, err := s.s3.GetObjectWithContext(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(e.FullKey),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("get object %s: %w", e.Address, err)
+		}
+		return obj.Body, nil
+	}
+}
+
 func (s *s3Walker) Marker() Mark {
 	return s.mark
 }
@@ -0,0 +1,385 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+var errFakeNoSuchKey = errors.New("no such key")
+
+// fakeS3 serves ListObjectsV2 from an in-memory set of objects, pageSize objects per page
+type fakeS3 struct {
+	s3iface.S3API
+	objects  map[string]int64
+	contents map[string][]byte
+	pageSize int
+	listed   int
+	mu       sync.Mutex
+	fetched  []string
+	// listDelay delays list calls by their call number, starting at 1
+	listDelay map[int]time.Duration
+}
+
+func newFakeS3(pageSize int, objects map[string]int64) *fakeS3 {
+	return &fakeS3{objects: objects, pageSize: pageSize}
+}
+
+// newFakeS3WithContent serves objects with the given content
+func newFakeS3WithContent(pageSize int, contents map[string][]byte) *fakeS3 {
+	objects := make(map[string]int64, len(contents))
+	for k, v := range contents {
+		objects[k] = int64(len(v))
+	}
+	return &fakeS3{objects: objects, contents: contents, pageSize: pageSize}
+}
+
+func (f *fakeS3) GetObjectWithContext(_ aws.Context, input *s3.GetObjectInput, _ ...request.Option) (*s3.GetObjectOutput, error) {
+	key := aws.StringValue(input.Key)
+	content, ok := f.contents[key]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", errFakeNoSuchKey, key)
+	}
+	f.mu.Lock()
+	f.fetched = append(f.fetched, key)
+	f.mu.Unlock()
+	return &s3.GetObjectOutput{
+		Body:          io.NopCloser(bytes.NewReader(content)),
+		ContentLength: aws.Int64(int64(len(content))),
+	}, nil
+}
+
+func (f *fakeS3) ListObjectsV2WithContext(ctx aws.Context, input *s3.ListObjectsV2Input, _ ...request.Option) (*s3.ListObjectsV2Output, error) {
+	f.mu.Lock()
+	f.listed++
+	delay := f.listDelay[f.listed]
+	f.mu.Unlock()
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	keys := make([]string, 0, len(f.objects))
+	for k := range f.objects {
+		if strings.HasPrefix(k, aws.StringValue(input.Prefix)) && k > aws.StringValue(input.StartAfter) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	offset := 0
+	if input.ContinuationToken != nil {
+		var err error
+		offset, err = strconv.Atoi(aws.StringValue(input.ContinuationToken))
+		if err != nil {
+			return nil, fmt.Errorf("invalid continuation token: %w", err)
+		}
+	}
+	end := offset + f.pageSize
+	if end > len(keys) {
+		end = len(keys)
+	}
+	output := &s3.ListObjectsV2Output{IsTruncated: aws.Bool(end < len(keys))}
+	if end < len(keys) {
+		output.NextContinuationToken = aws.String(strconv.Itoa(end))
+	}
+	for _, k := range keys[offset:end] {
+		output.Contents = append(output.Contents, &s3.Object{
+			Key:          aws.String(k),
+			ETag:         aws.String(`"` + k + `-etag"`),
+			LastModified: aws.Time(time.Unix(0, 0)),
+			Size:         aws.Int64(f.objects[k]),
+		})
+	}
+	return output, nil
+}
+
+func (f *fakeS3) numListed() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.listed
+}
+
+func walkS3(t *testing.T, walker *s3Walker, uri string, op WalkOptions) []ObjectStoreEntry {
+	t.Helper()
+	storageURI, err := url.Parse(uri)
+	if err != nil {
+		t.Fatalf("parse uri '%s': %s", uri, err)
+	}
+	var entries []ObjectStoreEntry
+	err = walker.Walk(context.Background(), storageURI, op, func(e ObjectStoreEntry) error {
+		entries = append(entries, e)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walk '%s': %s", uri, err)
+	}
+	return entries
+}
+
+func entriesKeys(entries []ObjectStoreEntry) []string {
+	keys := make([]string, len(entries))
+	for i, e := range entries {
+		keys[i] = e.FullKey
+	}
+	return keys
+}
+
+func TestS3WalkMaxBytes(t *testing.T) {
+	objects := make(map[string]int64)
+	for i := 0; i < 10; i++ {
+		objects[fmt.Sprintf("prefix/obj%02d", i)] = 100
+	}
+	walker := &s3Walker{s3: newFakeS3(3, objects)}
+
+	entries := walkS3(t, walker, "s3://bucket/prefix/", WalkOptions{MaxBytes: 250})
+	if len(entries) != 3 {
+		t.Fatalf("walked %d entries, expected 3 to reach the bytes budget", len(entries))
+	}
+	mark := walker.Marker()
+	if !mark.HasMore || mark.LastKey != "prefix/obj02" {
+		t.Fatalf("mark=%+v, expected more entries after 'prefix/obj02'", mark)
+	}
+
+	// resume from the mark, walking everything left
+	rest := walkS3(t, walker, "s3://bucket/prefix/", WalkOptions{After: mark.LastKey})
+	if len(rest) != 7 || rest[0].FullKey != "prefix/obj03" {
+		t.Fatalf("resumed walk keys=%v, expected the last 7 objects", entriesKeys(rest))
+	}
+	if walker.Marker().HasMore {
+		t.Fatal("expected walk to complete without more entries")
+	}
+
+	// budget larger than the total size walks everything
+	all := walkS3(t, walker, "s3://bucket/prefix/", WalkOptions{MaxBytes: 1000})
+	if len(all) != 10 || walker.Marker().HasMore {
+		t.Fatalf("walked %d entries (mark=%+v), expected all 10 entries", len(all), walker.Marker())
+	}
+}
+
+func TestS3WalkMaxBytesReadsWalkedContent(t *testing.T) {
+	contents := make(map[string][]byte)
+	for i := 0; i < 10; i++ {
+		contents[fmt.Sprintf("obj%02d", i)] = bytes.Repeat([]byte{'a'}, 100)
+	}
+	fake := newFakeS3WithContent(10, contents)
+	walker := &s3Walker{s3: fake}
+	entries := walkS3(t, walker, "s3://bucket/", WalkOptions{MaxBytes: 250, ComputeCRC32C: true, HashBelowBytes: 1000})
+	expected := []string{"obj00", "obj01", "obj02"}
+	if keys := entriesKeys(entries); strings.Join(keys, ",") != strings.Join(expected, ",") {
+		t.Fatalf("walked %s, expected %s", keys, expected)
+	}
+	// each walked entry is read once for its crc32c and once for its sha256
+	fetched := make(map[string]int)
+	for _, key := range fake.fetched {
+		fetched[key]++
+	}
+	if len(fetched) != len(expected) {
+		t.Fatalf("read content of %v, expected only the walked entries %s", fetched, expected)
+	}
+}
+
+func TestS3WalkAddressStyle(t *testing.T) {
+	walker := &s3Walker{s3: newFakeS3(10, map[string]int64{"path/to/obj": 1})}
+	full := walkS3(t, walker, "s3://bucket/path/", WalkOptions{})
+	if len(full) != 1 || full[0].Address != "s3://bucket/path/to/obj" {
+		t.Fatalf("full URL walk entries=%v, expected address 's3://bucket/path/to/obj'", full)
+	}
+	keyOnly := walkS3(t, walker, "s3://bucket/path/", WalkOptions{AddressStyle: AddressStyleKeyOnly})
+	if len(keyOnly) != 1 || keyOnly[0].Address != "path/to/obj" || keyOnly[0].FullKey != "path/to/obj" {
+		t.Fatalf("key only walk entries=%v, expected address 'path/to/obj'", keyOnly)
+	}
+}
+
+func TestS3WalkCompletionReason(t *testing.T) {
+	objects := make(map[string]int64)
+	for i := 0; i < 6; i++ {
+		objects[fmt.Sprintf("obj%02d", i)] = 100
+	}
+	storageURI, _ := url.Parse("s3://bucket/")
+	errWalkFn := errors.New("walk func failed")
+	tests := []struct {
+		name     string
+		op       WalkOptions
+		walkFn   func(cancel context.CancelFunc, e ObjectStoreEntry) error
+		expected CompletionReason
+	}{
+		{
+			name:     "completed",
+			expected: CompletionReasonCompleted,
+		},
+		{
+			name:     "limit_reached",
+			op:       WalkOptions{MaxBytes: 200},
+			expected: CompletionReasonLimitReached,
+		},
+		{
+			name: "cancelled",
+			walkFn: func(cancel context.CancelFunc, e ObjectStoreEntry) error {
+				cancel()
+				return nil
+			},
+			expected: CompletionReasonCancelled,
+		},
+		{
+			name: "error",
+			walkFn: func(_ context.CancelFunc, e ObjectStoreEntry) error {
+				return errWalkFn
+			},
+			expected: CompletionReasonError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			walker := &s3Walker{s3: newFakeS3(2, objects)}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			_ = walker.Walk(ctx, storageURI, tt.op, func(e ObjectStoreEntry) error {
+				if tt.walkFn == nil {
+					return nil
+				}
+				return tt.walkFn(cancel, e)
+			})
+			if reason := walker.Marker().Reason; reason != tt.expected {
+				t.Fatalf("walk ended with reason '%s', expected '%s'", reason, tt.expected)
+			}
+		})
+	}
+}
+
+func TestS3WalkPageTimeout(t *testing.T) {
+	fake := newFakeS3(2, map[string]int64{"a": 1, "b": 1, "c": 1, "d": 1})
+	fake.listDelay = map[int]time.Duration{2: time.Second}
+	walker := &s3Walker{s3: fake}
+	storageURI, _ := url.Parse("s3://bucket/")
+	var walked []string
+	err := walker.Walk(context.Background(), storageURI, WalkOptions{PageTimeout: 50 * time.Millisecond}, func(e ObjectStoreEntry) error {
+		walked = append(walked, e.FullKey)
+		return nil
+	})
+	if !errors.Is(err, ErrPageTimeout) {
+		t.Fatalf("walk err=%v, expected %s", err, ErrPageTimeout)
+	}
+	if len(walked) != 2 || walker.Marker().LastKey != "b" {
+		t.Fatalf("walked %v (mark=%+v), expected the first page before the timeout", walked, walker.Marker())
+	}
+
+	// pages within the timeout walk successfully
+	fake.listDelay = nil
+	entries := walkS3(t, walker, "s3://bucket/", WalkOptions{PageTimeout: time.Second})
+	if len(entries) != 4 {
+		t.Fatalf("walked %d entries, expected 4", len(entries))
+	}
+}
+
+func TestS3WalkTargetPrefix(t *testing.T) {
+	walker := &s3Walker{s3: newFakeS3(10, map[string]int64{"source/a": 1, "source/dir/b": 1})}
+	for _, targetPrefix := range []string{"target/path", "target/path/"} {
+		entries := walkS3(t, walker, "s3://bucket/source/", WalkOptions{TargetPrefix: targetPrefix})
+		expected := []ObjectStoreEntry{
+			{FullKey: "source/a", RelativeKey: "target/path/a", Address: "s3://bucket/source/a"},
+			{FullKey: "source/dir/b", RelativeKey: "target/path/dir/b", Address: "s3://bucket/source/dir/b"},
+		}
+		if len(entries) != len(expected) {
+			t.Fatalf("target prefix '%s' walked %d entries, expected %d", targetPrefix, len(entries), len(expected))
+		}
+		for i, e := range entries {
+			if e.FullKey != expected[i].FullKey || e.RelativeKey != expected[i].RelativeKey || e.Address != expected[i].Address {
+				t.Errorf("target prefix '%s' entry %s, expected %s", targetPrefix, e, expected[i])
+			}
+		}
+	}
+}
+
+func TestS3WalkSkip(t *testing.T) {
+	fake := newFakeS3WithContent(2, map[string][]byte{
+		"obj01": []byte("1"), "obj02.tmp": []byte("2"), "obj03": []byte("3"), "obj04.tmp": []byte("4"), "obj05": []byte("5"),
+	})
+	walker := &s3Walker{s3: fake}
+	op := WalkOptions{
+		ComputeCRC32C: true,
+		Skip: func(entry ObjectStoreEntry) bool {
+			return strings.HasSuffix(entry.FullKey, ".tmp")
+		},
+	}
+	entries := walkS3(t, walker, "s3://bucket/", op)
+	expected := []string{"obj01", "obj03", "obj05"}
+	if keys := entriesKeys(entries); strings.Join(keys, ",") != strings.Join(expected, ",") {
+		t.Fatalf("walked %s, expected %s", keys, expected)
+	}
+	sort.Strings(fake.fetched)
+	if strings.Join(fake.fetched, ",") != strings.Join(expected, ",") {
+		t.Fatalf("read content of %s, expected only %s", fake.fetched, expected)
+	}
+	mark := walker.Marker()
+	if mark.Skipped != 2 {
+		t.Fatalf("mark skipped %d entries, expected 2", mark.Skipped)
+	}
+
+	// the counter describes the last walk only
+	walkS3(t, walker, "s3://bucket/obj01", op)
+	if mark := walker.Marker(); mark.Skipped != 0 {
+		t.Fatalf("mark skipped %d entries on a walk without matches, expected 0", mark.Skipped)
+	}
+}
+
+func TestS3WalkSkipMaxBytes(t *testing.T) {
+	walker := &s3Walker{s3: newFakeS3(10, map[string]int64{
+		"a": 100, "b.tmp": 100, "c": 100, "d.tmp": 100, "e": 100, "f.tmp": 100,
+	})}
+	storageURI, _ := url.Parse("s3://bucket/")
+	op := WalkOptions{
+		MaxBytes: 150,
+		Skip: func(entry ObjectStoreEntry) bool {
+			return strings.HasSuffix(entry.FullKey, ".tmp")
+		},
+	}
+	// entries after the walk stops are not counted, they are listed again on resume
+	entries := walkS3(t, walker, storageURI.String(), op)
+	mark := walker.Marker()
+	if keys := entriesKeys(entries); strings.Join(keys, ",") != "a,c" || mark.LastKey != "c" || mark.Skipped != 1 {
+		t.Fatalf("walked %s (mark=%+v), expected a,c skipping only b.tmp", keys, mark)
+	}
+	op.MaxBytes = 0
+	op.After = mark.LastKey
+	entries = walkS3(t, walker, storageURI.String(), op)
+	if keys := entriesKeys(entries); strings.Join(keys, ",") != "e" || walker.Marker().Skipped != 2 {
+		t.Fatalf("resumed walk %s (mark=%+v), expected e skipping d.tmp and f.tmp", keys, walker.Marker())
+	}
+}
+
+func TestWalkOptionsRelativeKey(t *testing.T) {
+	tests := []struct {
+		targetPrefix string
+		key          string
+		basePath     string
+		expected     string
+	}{
+		{targetPrefix: "", key: "base/a", basePath: "base/", expected: "a"},
+		{targetPrefix: "target", key: "base/a", basePath: "base/", expected: "target/a"},
+		{targetPrefix: "target/", key: "base/a", basePath: "base/", expected: "target/a"},
+		{targetPrefix: "target/", key: "base/a", basePath: "base", expected: "target/a"},
+		{targetPrefix: "target", key: "a/b", basePath: "", expected: "target/a/b"},
+	}
+	for _, tt := range tests {
+		op := WalkOptions{TargetPrefix: tt.targetPrefix}
+		if got := op.relativeKey(tt.key, tt.basePath); got != tt.expected {
+			t.Errorf("relativeKey(%q, %q) with target prefix %q = %q, expected %q", tt.key, tt.basePath, tt.targetPrefix, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// throttleBackoff multiplies the request rate when a request is throttled
+	throttleBackoff = 0.5
+	// throttleIncrease is the part of the max rate added to the request rate for each second of requests that
+	// are not throttled
+	throttleIncrease = 0.05
+)
+
+var ErrInvalidThrottleRate = errors.New("invalid throttle rate")
+
+// AdaptiveThrottle limits the rate of requests sent by walks using it, adapting the rate to the service capacity:
+// the rate is cut by half when the service throttles a request, and increased gradually (additive increase,
+// multiplicative decrease) while requests succeed. The rate starts at, and is kept between, the min and max rates.
+// A throttle can be shared by concurrent walks on the same account.
+type AdaptiveThrottle struct {
+	minRate float64
+	maxRate float64
+
+	mu   sync.Mutex
+	rate float64
+	// next is the time the next request can be sent
+	next time.Time
+	// backoffAt is the time of the last rate decrease, throttled requests sent before it don't decrease the rate
+	// again
+	backoffAt time.Time
+
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// NewAdaptiveThrottle returns a throttle sending between minRate and maxRate requests per second
+func NewAdaptiveThrottle(minRate, maxRate float64) (*AdaptiveThrottle, error) {
+	if minRate <= 0 || maxRate < minRate || math.IsInf(maxRate, 0) {
+		return nil, fmt.Errorf("%w: min %g, max %g", ErrInvalidThrottleRate, minRate, maxRate)
+	}
+	return &AdaptiveThrottle{
+		minRate: minRate,
+		maxRate: maxRate,
+		rate:    maxRate,
+		now:     time.Now,
+		sleep:   sleepContext,
+	}, nil
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Rate returns the current requests per second rate
+func (t *AdaptiveThrottle) Rate() float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.rate
+}
+
+// wait blocks until a request can be sent at the current rate, returning the time the request is sent.
+// A nil throttle never blocks.
+func (t *AdaptiveThrottle) wait(ctx context.Context) (time.Time, error) {
+	if t == nil {
+		return time.Time{}, ctx.Err()
+	}
+	t.mu.Lock()
+	now := t.now()
+	if t.next.Before(now) {
+		t.next = now
+	}
+	sendAt := t.next
+	t.next = t.next.Add(time.Duration(float64(time.Second) / t.rate))
+	t.mu.Unlock()
+	if d := sendAt.Sub(now); d > 0 {
+		if err := t.sleep(ctx, d); err != nil {
+			return time.Time{}, err
+		}
+	}
+	return sendAt, nil
+}
+
+// observe adapts the rate to the response of a request sent at sentAt
+func (t *AdaptiveThrottle) observe(sentAt time.Time, throttled bool) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !throttled {
+		t.rate = math.Min(t.maxRate, t.rate+throttleIncrease*t.maxRate/t.rate)
+		return
+	}
+	if sentAt.Before(t.backoffAt) {
+		// sent at the previous rate, already decreased
+		return
+	}
+	t.rate = math.Max(t.minRate, t.rate*throttleBackoff)
+	t.backoffAt = t.now()
+}
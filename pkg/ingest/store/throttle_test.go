@@ -0,0 +1,86 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock advanced only by sleeping
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Sleep(_ context.Context, d time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	return nil
+}
+
+func newFakeClockThrottle(t *testing.T, minRate, maxRate float64) (*AdaptiveThrottle, *fakeClock) {
+	t.Helper()
+	throttle, err := NewAdaptiveThrottle(minRate, maxRate)
+	if err != nil {
+		t.Fatalf("new throttle: %s", err)
+	}
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	throttle.now = clock.Now
+	throttle.sleep = clock.Sleep
+	return throttle, clock
+}
+
+func TestNewAdaptiveThrottleInvalid(t *testing.T) {
+	for _, rates := range [][2]float64{{0, 10}, {-1, 10}, {10, 5}} {
+		if _, err := NewAdaptiveThrottle(rates[0], rates[1]); !errors.Is(err, ErrInvalidThrottleRate) {
+			t.Errorf("NewAdaptiveThrottle(%g, %g) err=%v, expected %s", rates[0], rates[1], err, ErrInvalidThrottleRate)
+		}
+	}
+}
+
+func TestAdaptiveThrottleBounds(t *testing.T) {
+	const minRate, maxRate = 2, 10
+	throttle, clock := newFakeClockThrottle(t, minRate, maxRate)
+	ctx := context.Background()
+
+	start := clock.Now()
+	for i := 0; i < maxRate+1; i++ {
+		if _, err := throttle.wait(ctx); err != nil {
+			t.Fatalf("wait: %s", err)
+		}
+	}
+	if elapsed := clock.Now().Sub(start); elapsed != time.Second {
+		t.Fatalf("sent %d requests in %s, expected 1s at the max rate", maxRate+1, elapsed)
+	}
+
+	for i := 0; i < 10; i++ {
+		sentAt, err := throttle.wait(ctx)
+		if err != nil {
+			t.Fatalf("wait: %s", err)
+		}
+		throttle.observe(sentAt, true)
+	}
+	if rate := throttle.Rate(); rate != minRate {
+		t.Fatalf("rate=%g after throttled requests, expected the min rate %d", rate, minRate)
+	}
+
+	for i := 0; i < 1000; i++ {
+		sentAt, err := throttle.wait(ctx)
+		if err != nil {
+			t.Fatalf("wait: %s", err)
+		}
+		throttle.observe(sentAt, false)
+	}
+	if rate := throttle.Rate(); rate != maxRate {
+		t.Fatalf("rate=%g after successful requests, expected the max rate %d", rate, maxRate)
+	}
+}
@@ -0,0 +1,18 @@
+package kv
+
+import "context"
+
+// Compactor is implemented by stores that can reclaim the space of deleted entries on demand
+type Compactor interface {
+	Compact(ctx context.Context) error
+}
+
+// Compact reclaims the space of deleted entries, i.e. after a large DeletePrefix. Stores that don't need it
+// return immediately.
+func Compact(ctx context.Context, store Store) error {
+	c, ok := store.(Compactor)
+	if !ok {
+		return nil
+	}
+	return c.Compact(ctx)
+}
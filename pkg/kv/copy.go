@@ -0,0 +1,190 @@
+package kv
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+)
+
+const (
+	// DefaultCopyBatchSize is the number of entries set together on the destination
+	DefaultCopyBatchSize = 100
+	// DefaultCopyProgressEvery is the number of entries copied between progress reports
+	DefaultCopyProgressEvery = 1000
+	// MaxCopyDiffKeys is the number of mismatched keys kept by VerifyCopy
+	MaxCopyDiffKeys = 100
+)
+
+type CopyOptions struct {
+	// Prefix limits the copy to the keys starting with it, nil copies all keys
+	Prefix []byte
+	// After resumes a copy after this key, the last key reported to Progress by the copy resumed
+	After []byte
+	// BatchSize is the number of entries set together on the destination, defaults to DefaultCopyBatchSize
+	BatchSize int
+	// ProgressEvery is the number of entries copied between progress reports, defaults to DefaultCopyProgressEvery
+	ProgressEvery int
+	// Progress, when set, is called after the batch reaching every ProgressEvery copied entries is set, and once
+	// the copy completes, with the number of entries copied so far and the last key copied
+	Progress func(copied int64, lastKey []byte)
+}
+
+// CopyDiff are the differences between the entries of a source and a destination store
+type CopyDiff struct {
+	// Missing is the number of source keys missing from the destination
+	Missing int64
+	// Changed is the number of keys with a different value in the destination
+	Changed int64
+	// Extra is the number of destination keys missing from the source
+	Extra int64
+	// Keys are the first mismatched keys, up to MaxCopyDiffKeys
+	Keys [][]byte
+}
+
+// Equal reports if the stores have the same entries
+func (d *CopyDiff) Equal() bool {
+	return d.Missing == 0 && d.Changed == 0 && d.Extra == 0
+}
+
+func (d *CopyDiff) addKey(key []byte) {
+	if len(d.Keys) < MaxCopyDiffKeys {
+		d.Keys = append(d.Keys, append([]byte(nil), key...))
+	}
+}
+
+// Copy sets the entries of src into dst in batches of BatchSize, scanning src in key order. Returns the number of
+// entries copied and the last key copied, also on failure, to resume the copy after it.
+// A batch is set using CompareAndSwapMany, a batch with keys already in dst is set one entry at a time. Entries in
+// dst are overwritten, keys found only in dst are kept. Writes to src while copying may or may not be copied, a copy
+// of a store in use should be followed by VerifyCopy.
+func Copy(ctx context.Context, dst, src Store, opts CopyOptions) (int64, []byte, error) {
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultCopyBatchSize
+	}
+	progressEvery := int64(opts.ProgressEvery)
+	if progressEvery <= 0 {
+		progressEvery = DefaultCopyProgressEvery
+	}
+	// scan from an empty key, not nil, to scan all keys with every driver
+	start := append([]byte{}, opts.Prefix...)
+	if opts.After != nil && bytes.Compare(opts.After, start) >= 0 {
+		// the first key after After
+		start = append(append([]byte(nil), opts.After...), 0)
+	}
+	iter, err := src.Scan(ctx, start)
+	if err != nil {
+		return 0, nil, fmt.Errorf("scan source: %w", err)
+	}
+	it := &PrefixIterator{Iterator: iter, Prefix: opts.Prefix}
+	defer it.Close()
+
+	var (
+		copied       int64
+		lastKey      []byte
+		reported     int64
+		nextProgress = progressEvery
+	)
+	batch := make([]CASOp, 0, batchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := dst.CompareAndSwapMany(ctx, batch)
+		switch {
+		case err == nil:
+			copied += int64(len(batch))
+			lastKey = batch[len(batch)-1].Key
+		case errors.Is(err, ErrPredicateFailed):
+			for _, op := range batch {
+				if err := dst.Set(ctx, op.Key, op.Value); err != nil {
+					return fmt.Errorf("set %s: %w", op.Key, err)
+				}
+				copied++
+				lastKey = op.Key
+			}
+		default:
+			return fmt.Errorf("set batch from %s: %w", batch[0].Key, err)
+		}
+		batch = batch[:0]
+		if opts.Progress != nil && copied >= nextProgress {
+			opts.Progress(copied, lastKey)
+			reported = copied
+			nextProgress = (copied/progressEvery + 1) * progressEvery
+		}
+		return nil
+	}
+	for it.Next() {
+		ent := it.Entry()
+		batch = append(batch, CASOp{Key: ent.Key, Value: ent.Value})
+		if len(batch) < batchSize {
+			continue
+		}
+		if err := flush(); err != nil {
+			return copied, lastKey, err
+		}
+	}
+	if err := it.Err(); err != nil {
+		return copied, lastKey, fmt.Errorf("scan source: %w", err)
+	}
+	if err := flush(); err != nil {
+		return copied, lastKey, err
+	}
+	if opts.Progress != nil && copied != reported {
+		opts.Progress(copied, lastKey)
+	}
+	return copied, lastKey, nil
+}
+
+// VerifyCopy compares the entries starting with prefix in src and dst, scanning both stores in key order
+func VerifyCopy(ctx context.Context, dst, src Store, prefix []byte) (*CopyDiff, error) {
+	prefix = append([]byte{}, prefix...)
+	srcIt, err := ScanPrefix(ctx, src, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("scan source: %w", err)
+	}
+	defer srcIt.Close()
+	dstIt, err := ScanPrefix(ctx, dst, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("scan destination: %w", err)
+	}
+	defer dstIt.Close()
+
+	diff := &CopyDiff{}
+	srcOK, dstOK := srcIt.Next(), dstIt.Next()
+	for srcOK || dstOK {
+		var cmp int
+		switch {
+		case !dstOK:
+			cmp = -1
+		case !srcOK:
+			cmp = 1
+		default:
+			cmp = bytes.Compare(srcIt.Entry().Key, dstIt.Entry().Key)
+		}
+		switch {
+		case cmp < 0:
+			diff.Missing++
+			diff.addKey(srcIt.Entry().Key)
+			srcOK = srcIt.Next()
+		case cmp > 0:
+			diff.Extra++
+			diff.addKey(dstIt.Entry().Key)
+			dstOK = dstIt.Next()
+		default:
+			if !bytes.Equal(srcIt.Entry().Value, dstIt.Entry().Value) {
+				diff.Changed++
+				diff.addKey(srcIt.Entry().Key)
+			}
+			srcOK, dstOK = srcIt.Next(), dstIt.Next()
+		}
+	}
+	if err := srcIt.Err(); err != nil {
+		return nil, fmt.Errorf("scan source: %w", err)
+	}
+	if err := dstIt.Err(); err != nil {
+		return nil, fmt.Errorf("scan destination: %w", err)
+	}
+	return diff, nil
+}
@@ -0,0 +1,132 @@
+package kv_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/go-test/deep"
+	"github.com/treeverse/lakefs/pkg/kv"
+	"github.com/treeverse/lakefs/pkg/kv/mem"
+)
+
+func openMemStore(t *testing.T, ctx context.Context) kv.Store {
+	t.Helper()
+	store, err := (&mem.Driver{}).Open(ctx, "")
+	if err != nil {
+		t.Fatalf("open mem store: %s", err)
+	}
+	t.Cleanup(store.Close)
+	return store
+}
+
+func TestCopy(t *testing.T) {
+	ctx := context.Background()
+	src := openMemStore(t, ctx)
+	for i := 0; i < 10; i++ {
+		if err := src.Set(ctx, []byte(fmt.Sprintf("copy/key%02d", i)), []byte(fmt.Sprintf("value%02d", i))); err != nil {
+			t.Fatalf("set: %s", err)
+		}
+	}
+	if err := src.Set(ctx, []byte("other/key"), []byte("value")); err != nil {
+		t.Fatalf("set: %s", err)
+	}
+
+	t.Run("resume", func(t *testing.T) {
+		dst := openMemStore(t, ctx)
+		var progress []string
+		copied, lastKey, err := kv.Copy(ctx, dst, src, kv.CopyOptions{
+			Prefix:        []byte("copy/"),
+			BatchSize:     2,
+			ProgressEvery: 4,
+			Progress: func(copied int64, lastKey []byte) {
+				progress = append(progress, fmt.Sprintf("%d:%s", copied, lastKey))
+			},
+		})
+		if err != nil {
+			t.Fatalf("copy: %s", err)
+		}
+		if copied != 10 || string(lastKey) != "copy/key09" {
+			t.Fatalf("copied %d entries up to %s, expected 10 up to copy/key09", copied, lastKey)
+		}
+		if diff := deep.Equal(progress, []string{"4:copy/key03", "8:copy/key07", "10:copy/key09"}); diff != nil {
+			t.Fatal("copy progress didn't match:", diff)
+		}
+
+		// resume a copy of all keys after the last key of the first batch
+		dst = openMemStore(t, ctx)
+		copied, _, err = kv.Copy(ctx, dst, src, kv.CopyOptions{After: []byte("copy/key03")})
+		if err != nil {
+			t.Fatalf("resume copy: %s", err)
+		}
+		if copied != 7 {
+			t.Fatalf("resumed copy copied %d entries, expected 7", copied)
+		}
+		diff, err := kv.VerifyCopy(ctx, dst, src, nil)
+		if err != nil {
+			t.Fatalf("verify: %s", err)
+		}
+		if diff.Missing != 4 || diff.Changed != 0 || diff.Extra != 0 || string(diff.Keys[0]) != "copy/key00" {
+			t.Fatalf("verify resumed copy diff=%+v, expected the first 4 keys missing", diff)
+		}
+	})
+
+	t.Run("verify", func(t *testing.T) {
+		dst := openMemStore(t, ctx)
+		if _, _, err := kv.Copy(ctx, dst, src, kv.CopyOptions{}); err != nil {
+			t.Fatalf("copy: %s", err)
+		}
+		diff, err := kv.VerifyCopy(ctx, dst, src, nil)
+		if err != nil {
+			t.Fatalf("verify: %s", err)
+		}
+		if !diff.Equal() {
+			t.Fatalf("verify copy diff=%+v, expected equal stores", diff)
+		}
+
+		if err := dst.Set(ctx, []byte("copy/key05"), []byte("changed")); err != nil {
+			t.Fatalf("set: %s", err)
+		}
+		if err := dst.Set(ctx, []byte("copy/key10"), []byte("extra")); err != nil {
+			t.Fatalf("set: %s", err)
+		}
+		if err := dst.Delete(ctx, []byte("copy/key07")); err != nil {
+			t.Fatalf("delete: %s", err)
+		}
+		diff, err = kv.VerifyCopy(ctx, dst, src, []byte("copy/"))
+		if err != nil {
+			t.Fatalf("verify: %s", err)
+		}
+		keys := make([]string, 0, len(diff.Keys))
+		for _, k := range diff.Keys {
+			keys = append(keys, string(k))
+		}
+		if diff.Missing != 1 || diff.Changed != 1 || diff.Extra != 1 {
+			t.Fatalf("verify diff=%+v, expected a missing, a changed and an extra key", diff)
+		}
+		if d := deep.Equal(keys, []string{"copy/key05", "copy/key07", "copy/key10"}); d != nil {
+			t.Fatal("verify diff keys didn't match:", d)
+		}
+	})
+	t.Run("overwrite", func(t *testing.T) {
+		dst := openMemStore(t, ctx)
+		if err := dst.Set(ctx, []byte("copy/key05"), []byte("changed")); err != nil {
+			t.Fatalf("set: %s", err)
+		}
+		// the batch holding the existing key is set one entry at a time
+		copied, _, err := kv.Copy(ctx, dst, src, kv.CopyOptions{BatchSize: 4})
+		if err != nil {
+			t.Fatalf("copy: %s", err)
+		}
+		if copied != 11 {
+			t.Fatalf("copied %d entries, expected 11", copied)
+		}
+		diff, err := kv.VerifyCopy(ctx, dst, src, nil)
+		if err != nil {
+			t.Fatalf("verify: %s", err)
+		}
+		if !diff.Equal() {
+			t.Fatalf("verify copy diff=%+v, expected the existing key overwritten", diff)
+		}
+	})
+}
@@ -0,0 +1,70 @@
+package kv_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/treeverse/lakefs/pkg/kv"
+	"github.com/treeverse/lakefs/pkg/kv/mem"
+)
+
+// bufferedStore holds Set writes in memory until Flush
+type bufferedStore struct {
+	kv.Store
+	pending []kv.Entry
+}
+
+func (s *bufferedStore) Set(_ context.Context, key, value []byte) error {
+	s.pending = append(s.pending, kv.Entry{Key: key, Value: value})
+	return nil
+}
+
+func (s *bufferedStore) Flush(ctx context.Context) error {
+	for _, ent := range s.pending {
+		if err := s.Store.Set(ctx, ent.Key, ent.Value); err != nil {
+			return err
+		}
+	}
+	s.pending = nil
+	return s.Store.Flush(ctx)
+}
+
+func TestFlushBufferedStore(t *testing.T) {
+	ctx := context.Background()
+	backend, err := (&mem.Driver{}).Open(ctx, "")
+	if err != nil {
+		t.Fatalf("failed to open store: %s", err)
+	}
+	defer backend.Close()
+	store := kv.NewTimeoutStore(&bufferedStore{Store: backend}, 0)
+
+	entries := []kv.Entry{
+		{Key: []byte("a"), Value: []byte("1")},
+		{Key: []byte("b"), Value: []byte("2")},
+	}
+	for _, ent := range entries {
+		if err := store.Set(ctx, ent.Key, ent.Value); err != nil {
+			t.Fatalf("Set key=%s: %s", ent.Key, err)
+		}
+	}
+	reader := backend.Clone()
+	defer reader.Close()
+	if _, err := reader.Get(ctx, entries[0].Key); !errors.Is(err, kv.ErrNotFound) {
+		t.Fatalf("Get buffered key before Flush err=%v, expected %s", err, kv.ErrNotFound)
+	}
+
+	if err := store.Flush(ctx); err != nil {
+		t.Fatalf("Flush: %s", err)
+	}
+	for _, ent := range entries {
+		value, err := reader.Get(ctx, ent.Key)
+		if err != nil {
+			t.Fatalf("Get key=%s after Flush: %s", ent.Key, err)
+		}
+		if !bytes.Equal(value, ent.Value) {
+			t.Fatalf("Get key=%s after Flush value=%s, expected value=%s", ent.Key, value, ent.Value)
+		}
+	}
+}
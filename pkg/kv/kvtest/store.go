@@ -5,6 +5,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"sync"
 	"testing"
@@ -48,12 +49,26 @@ func TestDriver(t *testing.T, name, dsn string) {
 	t.Run("Driver_Open", func(t *testing.T) { testDriverOpen(t, ms) })
 	t.Run("Store_SetGet", func(t *testing.T) { testStoreSetGet(t, ms) })
 	t.Run("Store_SetIf", func(t *testing.T) { testStoreSetIf(t, ms) })
+	t.Run("Store_SetIfFunc", func(t *testing.T) { testStoreSetIfFunc(t, ms) })
+	t.Run("Store_GetOrSet", func(t *testing.T) { testStoreGetOrSet(t, ms) })
+	t.Run("Store_CompareAndSwapMany", func(t *testing.T) { testStoreCompareAndSwapMany(t, ms) })
 	t.Run("Store_Delete", func(t *testing.T) { testStoreDelete(t, ms) })
+	t.Run("Store_DeleteIf", func(t *testing.T) { testStoreDeleteIf(t, ms) })
 	t.Run("Store_Scan", func(t *testing.T) { testStoreScan(t, ms) })
+	t.Run("Store_ScanSnapshot", func(t *testing.T) { testStoreScanSnapshot(t, ms) })
 	t.Run("Store_MissingArgument", func(t *testing.T) { testStoreMissingArgument(t, ms) })
 	t.Run("ScanPrefix", func(t *testing.T) { testScanPrefix(t, ms) })
 	t.Run("DeleteWhileIterating", func(t *testing.T) { testDeleteWhileIterPrefix(t, ms) })
 	t.Run("DeleteWhileIteratingSamePrefix", func(t *testing.T) { testDeleteWhileIterSamePrefix(t, ms) })
+	t.Run("ScanWhileWriting", func(t *testing.T) { testScanWhileWriting(t, ms) })
+	t.Run("Store_Clone", func(t *testing.T) { testStoreClone(t, ms) })
+	t.Run("Store_Flush", func(t *testing.T) { testStoreFlush(t, ms) })
+	t.Run("Store_DeletePrefix", func(t *testing.T) { testStoreDeletePrefix(t, ms) })
+	t.Run("Store_Rename", func(t *testing.T) { testStoreRename(t, ms) })
+	t.Run("Store_RenamePrefix", func(t *testing.T) { testStoreRenamePrefix(t, ms) })
+	t.Run("Store_DeleteBatch", func(t *testing.T) { testStoreDeleteBatch(t, ms) })
+	t.Run("Store_ListKeys", func(t *testing.T) { testStoreListKeys(t, ms) })
+	t.Run("Store_GetRange", func(t *testing.T) { testStoreGetRange(t, ms) })
 }
 
 func testDriverOpen(t *testing.T, ms MakeStore) {
@@ -64,6 +79,67 @@ func testDriverOpen(t *testing.T, ms MakeStore) {
 	store2.Close()
 }
 
+func testStoreClone(t *testing.T, ms MakeStore) {
+	ctx := context.Background()
+	store := ms(t, ctx)
+	clone := store.Clone()
+	defer clone.Close()
+
+	key := uniqueKey("clone")
+	value := []byte("clone value")
+	if err := store.Set(ctx, key, value); err != nil {
+		t.Fatalf("failed to set key '%s' using store: %s", key, err)
+	}
+
+	// closing the original store should keep the clone usable
+	store.Close()
+	val, err := clone.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("failed to get key '%s' using clone after store close: %s", key, err)
+	}
+	if !bytes.Equal(val, value) {
+		t.Fatalf("key='%s' value='%s' doesn't match, expected='%s'", key, val, value)
+	}
+
+	// clone of a clone shares the same storage
+	another := clone.Clone()
+	defer another.Close()
+	value2 := []byte("another clone value")
+	if err := another.Set(ctx, key, value2); err != nil {
+		t.Fatalf("failed to set key '%s' using clone of clone: %s", key, err)
+	}
+	val, err = clone.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("failed to get key '%s' using clone: %s", key, err)
+	}
+	if !bytes.Equal(val, value2) {
+		t.Fatalf("key='%s' value='%s' doesn't match, expected='%s'", key, val, value2)
+	}
+}
+
+func testStoreFlush(t *testing.T, ms MakeStore) {
+	ctx := context.Background()
+	store := ms(t, ctx)
+	defer store.Close()
+
+	entries := setupSampleData(t, ctx, store, string(uniqueKey("flush")), 3)
+	if err := store.Flush(ctx); err != nil {
+		t.Fatalf("Flush: %s", err)
+	}
+	// a new handle reads all the writes made before flush
+	reader := store.Clone()
+	defer reader.Close()
+	for _, ent := range entries {
+		value, err := reader.Get(ctx, ent.Key)
+		if err != nil {
+			t.Fatalf("Get key=%s after Flush: %s", ent.Key, err)
+		}
+		if !bytes.Equal(value, ent.Value) {
+			t.Fatalf("Get key=%s after Flush value=%s, expected value=%s", ent.Key, value, ent.Value)
+		}
+	}
+}
+
 func testStoreSetGet(t *testing.T, ms MakeStore) {
 	ctx := context.Background()
 	store := ms(t, ctx)
@@ -143,6 +219,437 @@ func testStoreDelete(t *testing.T, ms MakeStore) {
 	})
 }
 
+func testStoreDeleteIf(t *testing.T, ms MakeStore) {
+	ctx := context.Background()
+	store := ms(t, ctx)
+	defer store.Close()
+
+	t.Run("match", func(t *testing.T) {
+		key := uniqueKey("delete-if-match")
+		if err := store.Set(ctx, key, []byte("v1")); err != nil {
+			t.Fatalf("failed to set key='%s': %s", key, err)
+		}
+		if err := store.DeleteIf(ctx, key, []byte("v1")); err != nil {
+			t.Fatalf("DeleteIf matching value key='%s': %s", key, err)
+		}
+		if _, err := store.Get(ctx, key); !errors.Is(err, kv.ErrNotFound) {
+			t.Fatalf("Get after DeleteIf key='%s' err=%v, expected %s", key, err, kv.ErrNotFound)
+		}
+	})
+
+	t.Run("mismatch", func(t *testing.T) {
+		key := uniqueKey("delete-if-mismatch")
+		if err := store.Set(ctx, key, []byte("v2")); err != nil {
+			t.Fatalf("failed to set key='%s': %s", key, err)
+		}
+		if err := store.DeleteIf(ctx, key, []byte("v1")); !errors.Is(err, kv.ErrPredicateFailed) {
+			t.Fatalf("DeleteIf mismatching value key='%s' err=%v, expected %s", key, err, kv.ErrPredicateFailed)
+		}
+		if value, err := store.Get(ctx, key); err != nil || !bytes.Equal(value, []byte("v2")) {
+			t.Fatalf("Get after failed DeleteIf key='%s' value=%s err=%v, expected value v2", key, value, err)
+		}
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		key := uniqueKey("delete-if-missing")
+		if err := store.DeleteIf(ctx, key, []byte("v1")); !errors.Is(err, kv.ErrPredicateFailed) {
+			t.Fatalf("DeleteIf missing key='%s' err=%v, expected %s", key, err, kv.ErrPredicateFailed)
+		}
+		if err := store.DeleteIf(ctx, key, nil); !errors.Is(err, kv.ErrMissingValue) {
+			t.Fatalf("DeleteIf nil value predicate err=%v, expected %s", err, kv.ErrMissingValue)
+		}
+	})
+}
+
+func testStoreDeleteBatch(t *testing.T, ms MakeStore) {
+	ctx := context.Background()
+	store := ms(t, ctx)
+	defer store.Close()
+
+	samplePrefix := uniqueKey("delete-batch")
+	sampleData := setupSampleData(t, ctx, store, string(samplePrefix), 10)
+
+	// delete every other key, together with keys that don't exist
+	var (
+		keys     [][]byte
+		expected []kv.Entry
+	)
+	for i, ent := range sampleData {
+		if i%2 == 0 {
+			keys = append(keys, ent.Key)
+		} else {
+			expected = append(expected, ent)
+		}
+	}
+	keys = append(keys, uniqueKey("delete-batch-missing-1"), uniqueKey("delete-batch-missing-2"))
+	if err := store.DeleteBatch(ctx, keys); err != nil {
+		t.Fatalf("DeleteBatch %d keys: %s", len(keys), err)
+	}
+
+	entries := scanPrefixEntries(t, ctx, store, samplePrefix)
+	if diff := deep.Equal(entries, expected); diff != nil {
+		t.Fatal("entries after DeleteBatch didn't match:", diff)
+	}
+
+	t.Run("empty", func(t *testing.T) {
+		if err := store.DeleteBatch(ctx, nil); err != nil {
+			t.Fatalf("DeleteBatch with no keys: %s", err)
+		}
+	})
+
+	t.Run("nil_key", func(t *testing.T) {
+		err := store.DeleteBatch(ctx, [][]byte{expected[0].Key, nil})
+		if !errors.Is(err, kv.ErrMissingKey) {
+			t.Fatalf("DeleteBatch with nil key err=%v, expected %s", err, kv.ErrMissingKey)
+		}
+		if _, err := store.Get(ctx, expected[0].Key); err != nil {
+			t.Fatalf("Get key '%s' after failed DeleteBatch: %s", expected[0].Key, err)
+		}
+	})
+}
+
+func testStoreListKeys(t *testing.T, ms MakeStore) {
+	ctx := context.Background()
+	store := ms(t, ctx)
+	defer store.Close()
+
+	// 'list-keys' keys are followed by 'list-keyz' keys, which should not be listed
+	samplePrefix := uniqueKey("list-keys")
+	sampleData := setupSampleData(t, ctx, store, string(samplePrefix)+"-", 20)
+	_ = setupSampleData(t, ctx, store, string(uniqueKey("list-keyz")), 5)
+
+	listKeys := func(t *testing.T, prefix []byte) [][]byte {
+		t.Helper()
+		iter, err := store.ListKeys(ctx, prefix)
+		if err != nil {
+			t.Fatalf("ListKeys '%s': %s", prefix, err)
+		}
+		defer iter.Close()
+		var keys [][]byte
+		for iter.Next() {
+			key := iter.Key()
+			if key == nil {
+				t.Fatal("ListKeys got nil key")
+			}
+			keys = append(keys, key)
+		}
+		if err := iter.Err(); err != nil {
+			t.Fatalf("ListKeys '%s' ended with an error: %s", prefix, err)
+		}
+		return keys
+	}
+
+	t.Run("prefix", func(t *testing.T) {
+		keys := listKeys(t, samplePrefix)
+		expected := make([][]byte, len(sampleData))
+		for i, ent := range sampleData {
+			expected[i] = ent.Key
+		}
+		if diff := deep.Equal(keys, expected); diff != nil {
+			t.Fatal("ListKeys keys didn't match:", diff)
+		}
+	})
+
+	t.Run("no_match", func(t *testing.T) {
+		keys := listKeys(t, uniqueKey("list-keys-no-match"))
+		if len(keys) != 0 {
+			t.Fatalf("ListKeys with no matching keys got %d keys", len(keys))
+		}
+	})
+
+	t.Run("ordered", func(t *testing.T) {
+		keys := listKeys(t, []byte(runTestID))
+		if len(keys) < len(sampleData) {
+			t.Fatalf("ListKeys test run prefix got %d keys, expected at least %d", len(keys), len(sampleData))
+		}
+		for i := 1; i < len(keys); i++ {
+			if bytes.Compare(keys[i-1], keys[i]) >= 0 {
+				t.Fatalf("ListKeys keys not ordered: '%s' before '%s'", keys[i-1], keys[i])
+			}
+		}
+	})
+}
+
+func testStoreGetRange(t *testing.T, ms MakeStore) {
+	ctx := context.Background()
+	store := ms(t, ctx)
+	defer store.Close()
+
+	prefix := string(uniqueKey("get-range"))
+	entries := setupSampleData(t, ctx, store, prefix, 5)
+	// keys sharing the prefix bytes, but not the scoped prefix
+	setupSampleData(t, ctx, store, prefix+"-other", 3)
+	scoped := []byte(prefix + "-key-")
+
+	t.Run("prefix", func(t *testing.T) {
+		res, err := store.GetRange(ctx, scoped, 0)
+		if err != nil {
+			t.Fatalf("GetRange '%s': %s", scoped, err)
+		}
+		if len(res) != len(entries) {
+			t.Fatalf("GetRange '%s' returned %d entries, expected %d", scoped, len(res), len(entries))
+		}
+		for _, ent := range entries {
+			if value, ok := res[string(ent.Key)]; !ok || !bytes.Equal(value, ent.Value) {
+				t.Fatalf("GetRange '%s' key '%s' value=%s, expected value=%s", scoped, ent.Key, value, ent.Value)
+			}
+		}
+	})
+
+	t.Run("limit", func(t *testing.T) {
+		const limit = 3
+		res, err := store.GetRange(ctx, scoped, limit)
+		if err != nil {
+			t.Fatalf("GetRange '%s' limit %d: %s", scoped, limit, err)
+		}
+		if len(res) != limit {
+			t.Fatalf("GetRange '%s' limit %d returned %d entries", scoped, limit, len(res))
+		}
+		for _, ent := range entries[:limit] {
+			if _, ok := res[string(ent.Key)]; !ok {
+				t.Fatalf("GetRange '%s' limit %d missing key '%s', expected first keys by order", scoped, limit, ent.Key)
+			}
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		noMatch := uniqueKey("get-range-no-match")
+		res, err := store.GetRange(ctx, noMatch, 10)
+		if err != nil {
+			t.Fatalf("GetRange '%s': %s", noMatch, err)
+		}
+		if len(res) != 0 {
+			t.Fatalf("GetRange '%s' returned %d entries, expected none", noMatch, len(res))
+		}
+	})
+}
+
+// scanPrefixEntries returns all the entries in store with keys starting with prefix
+func scanPrefixEntries(t *testing.T, ctx context.Context, store kv.Store, prefix []byte) []kv.Entry {
+	t.Helper()
+	scan, err := kv.ScanPrefix(ctx, store, prefix)
+	if err != nil {
+		t.Fatalf("ScanPrefix '%s': %s", prefix, err)
+	}
+	defer scan.Close()
+	var entries []kv.Entry
+	for scan.Next() {
+		entries = append(entries, *scan.Entry())
+	}
+	if err := scan.Err(); err != nil {
+		t.Fatalf("ScanPrefix '%s' ended with an error: %s", prefix, err)
+	}
+	return entries
+}
+
+func testStoreRename(t *testing.T, ms MakeStore) {
+	ctx := context.Background()
+	store := ms(t, ctx)
+	defer store.Close()
+
+	setKey := func(t *testing.T, key, value []byte) {
+		t.Helper()
+		if err := store.Set(ctx, key, value); err != nil {
+			t.Fatalf("Set while testing Rename - key=%s value=%s: %s", key, value, err)
+		}
+	}
+	verifyValue := func(t *testing.T, key, expected []byte) {
+		t.Helper()
+		value, err := store.Get(ctx, key)
+		if err != nil {
+			t.Fatalf("Get key=%s: %s", key, err)
+		}
+		if !bytes.Equal(value, expected) {
+			t.Fatalf("Get key=%s value=%s, expected value=%s", key, value, expected)
+		}
+	}
+	verifyMissing := func(t *testing.T, key []byte) {
+		t.Helper()
+		if _, err := store.Get(ctx, key); !errors.Is(err, kv.ErrNotFound) {
+			t.Fatalf("Get renamed key=%s err=%v, expected %s", key, err, kv.ErrNotFound)
+		}
+	}
+
+	t.Run("rename", func(t *testing.T) {
+		oldKey, newKey := uniqueKey("rename-old"), uniqueKey("rename-new")
+		value := []byte("value")
+		setKey(t, oldKey, value)
+		if err := store.Rename(ctx, oldKey, newKey, false); err != nil {
+			t.Fatalf("Rename %s to %s: %s", oldKey, newKey, err)
+		}
+		verifyValue(t, newKey, value)
+		verifyMissing(t, oldKey)
+	})
+
+	t.Run("collision", func(t *testing.T) {
+		oldKey, newKey := uniqueKey("rename-collision-old"), uniqueKey("rename-collision-new")
+		oldValue, newValue := []byte("old"), []byte("new")
+		setKey(t, oldKey, oldValue)
+		setKey(t, newKey, newValue)
+		err := store.Rename(ctx, oldKey, newKey, false)
+		if !errors.Is(err, kv.ErrPredicateFailed) {
+			t.Fatalf("Rename to existing key err=%v, expected %s", err, kv.ErrPredicateFailed)
+		}
+		verifyValue(t, oldKey, oldValue)
+		verifyValue(t, newKey, newValue)
+
+		if err := store.Rename(ctx, oldKey, newKey, true); err != nil {
+			t.Fatalf("Rename overwrite %s to %s: %s", oldKey, newKey, err)
+		}
+		verifyValue(t, newKey, oldValue)
+		verifyMissing(t, oldKey)
+	})
+
+	t.Run("missing_source", func(t *testing.T) {
+		oldKey, newKey := uniqueKey("rename-missing-old"), uniqueKey("rename-missing-new")
+		err := store.Rename(ctx, oldKey, newKey, true)
+		if !errors.Is(err, kv.ErrNotFound) {
+			t.Fatalf("Rename missing key err=%v, expected %s", err, kv.ErrNotFound)
+		}
+		verifyMissing(t, newKey)
+	})
+}
+
+func testStoreRenamePrefix(t *testing.T, ms MakeStore) {
+	ctx := context.Background()
+	store := ms(t, ctx)
+	defer store.Close()
+	const sampleItems = 20
+
+	verifyRenamed := func(t *testing.T, entries []kv.Entry, oldPrefix, newPrefix []byte) {
+		t.Helper()
+		for _, ent := range entries {
+			if _, err := store.Get(ctx, ent.Key); !errors.Is(err, kv.ErrNotFound) {
+				t.Fatalf("Get renamed key=%s err=%v, expected %s", ent.Key, err, kv.ErrNotFound)
+			}
+			newKey := kv.RenamedKey(ent.Key, oldPrefix, newPrefix)
+			value, err := store.Get(ctx, newKey)
+			if err != nil {
+				t.Fatalf("Get key=%s: %s", newKey, err)
+			}
+			if !bytes.Equal(value, ent.Value) {
+				t.Fatalf("Get key=%s value=%s, expected value=%s", newKey, value, ent.Value)
+			}
+		}
+	}
+
+	t.Run("rename", func(t *testing.T) {
+		oldPrefix, newPrefix := uniqueKey("rename-prefix-old/"), uniqueKey("rename-prefix-new/")
+		entries := setupSampleData(t, ctx, store, string(oldPrefix), sampleItems)
+		other := setupSampleData(t, ctx, store, string(uniqueKey("rename-prefix-other/")), sampleItems)
+		renamed, err := store.RenamePrefix(ctx, oldPrefix, newPrefix, false)
+		if err != nil {
+			t.Fatalf("RenamePrefix %s to %s: %s", oldPrefix, newPrefix, err)
+		}
+		if renamed != sampleItems {
+			t.Fatalf("RenamePrefix renamed %d keys, expected %d", renamed, sampleItems)
+		}
+		verifyRenamed(t, entries, oldPrefix, newPrefix)
+		for _, ent := range other {
+			if _, err := store.Get(ctx, ent.Key); err != nil {
+				t.Fatalf("Get key=%s outside the renamed prefix: %s", ent.Key, err)
+			}
+		}
+	})
+
+	t.Run("collision", func(t *testing.T) {
+		oldPrefix, newPrefix := uniqueKey("rename-prefix-collision-old/"), uniqueKey("rename-prefix-collision-new/")
+		entries := setupSampleData(t, ctx, store, string(oldPrefix), sampleItems)
+		collision := kv.RenamedKey(entries[sampleItems/2].Key, oldPrefix, newPrefix)
+		if err := store.Set(ctx, collision, []byte("existing")); err != nil {
+			t.Fatalf("Set while testing RenamePrefix - key=%s: %s", collision, err)
+		}
+		_, err := store.RenamePrefix(ctx, oldPrefix, newPrefix, false)
+		if !errors.Is(err, kv.ErrPredicateFailed) {
+			t.Fatalf("RenamePrefix with existing key err=%v, expected %s", err, kv.ErrPredicateFailed)
+		}
+		if got := scanPrefixEntries(t, ctx, store, oldPrefix); len(got) != sampleItems {
+			t.Fatalf("RenamePrefix failed with %d keys left in the old prefix, expected all %d", len(got), sampleItems)
+		}
+
+		renamed, err := store.RenamePrefix(ctx, oldPrefix, newPrefix, true)
+		if err != nil {
+			t.Fatalf("RenamePrefix overwrite %s to %s: %s", oldPrefix, newPrefix, err)
+		}
+		if renamed != sampleItems {
+			t.Fatalf("RenamePrefix overwrite renamed %d keys, expected %d", renamed, sampleItems)
+		}
+		verifyRenamed(t, entries, oldPrefix, newPrefix)
+	})
+
+	t.Run("overlapping", func(t *testing.T) {
+		oldPrefix := uniqueKey("rename-prefix-overlap/")
+		newPrefix := append(append([]byte{}, oldPrefix...), "nested/"...)
+		_, err := store.RenamePrefix(ctx, oldPrefix, newPrefix, false)
+		if !errors.Is(err, kv.ErrOverlappingPrefix) {
+			t.Fatalf("RenamePrefix to a nested prefix err=%v, expected %s", err, kv.ErrOverlappingPrefix)
+		}
+	})
+}
+
+func testStoreDeletePrefix(t *testing.T, ms MakeStore) {
+	ctx := context.Background()
+	store := ms(t, ctx)
+	defer store.Close()
+
+	// setup data - 'prefix' keys are a prefix of 'prefix-more' keys, make sure only the requested set is deleted
+	deletePrefix := uniqueKey("delete-prefix")
+	morePrefix := uniqueKey("delete-prefix-more")
+	otherPrefix := uniqueKey("delete-other")
+	const sampleItems = 50
+	toDelete := setupSampleData(t, ctx, store, string(deletePrefix)+"-set", sampleItems)
+	toDelete = append(toDelete, setupSampleData(t, ctx, store, string(morePrefix), sampleItems)...)
+	toKeep := setupSampleData(t, ctx, store, string(otherPrefix), sampleItems)
+
+	t.Run("prefix", func(t *testing.T) {
+		deleted, err := store.DeletePrefix(ctx, deletePrefix, false)
+		if err != nil {
+			t.Fatalf("DeletePrefix '%s': %s", deletePrefix, err)
+		}
+		if deleted != int64(len(toDelete)) {
+			t.Fatalf("DeletePrefix '%s' deleted %d keys, expected %d", deletePrefix, deleted, len(toDelete))
+		}
+		for _, ent := range toDelete {
+			if _, err := store.Get(ctx, ent.Key); !errors.Is(err, kv.ErrNotFound) {
+				t.Fatalf("Get deleted key '%s' err=%v, expected %s", ent.Key, err, kv.ErrNotFound)
+			}
+		}
+		for _, ent := range toKeep {
+			val, err := store.Get(ctx, ent.Key)
+			if err != nil {
+				t.Fatalf("Get key '%s' after DeletePrefix: %s", ent.Key, err)
+			}
+			if !bytes.Equal(val, ent.Value) {
+				t.Fatalf("key='%s' value='%s' doesn't match, expected='%s'", ent.Key, val, ent.Value)
+			}
+		}
+	})
+
+	t.Run("no_match", func(t *testing.T) {
+		deleted, err := store.DeletePrefix(ctx, uniqueKey("delete-prefix-no-match"), false)
+		if err != nil {
+			t.Fatalf("DeletePrefix with no matching keys: %s", err)
+		}
+		if deleted != 0 {
+			t.Fatalf("DeletePrefix with no matching keys deleted %d keys, expected none", deleted)
+		}
+	})
+
+	t.Run("empty_prefix", func(t *testing.T) {
+		_, err := store.DeletePrefix(ctx, nil, false)
+		if !errors.Is(err, kv.ErrMissingKey) {
+			t.Fatalf("DeletePrefix using nil prefix err=%v, expected %s", err, kv.ErrMissingKey)
+		}
+		_, err = store.DeletePrefix(ctx, []byte{}, false)
+		if !errors.Is(err, kv.ErrMissingKey) {
+			t.Fatalf("DeletePrefix using empty prefix err=%v, expected %s", err, kv.ErrMissingKey)
+		}
+		if _, err := store.Get(ctx, toKeep[0].Key); err != nil {
+			t.Fatalf("Get key '%s' after rejected DeletePrefix: %s", toKeep[0].Key, err)
+		}
+	})
+}
+
 func testStoreSetIf(t *testing.T, ms MakeStore) {
 	ctx := context.Background()
 	store := ms(t, ctx)
@@ -203,6 +710,271 @@ func testStoreSetIf(t *testing.T, ms MakeStore) {
 	})
 }
 
+func testStoreCompareAndSwapMany(t *testing.T, ms MakeStore) {
+	ctx := context.Background()
+	store := ms(t, ctx)
+	defer store.Close()
+
+	setup := func(t *testing.T, name string) (existing, missing []byte) {
+		t.Helper()
+		existing = uniqueKey(name + "-existing")
+		missing = uniqueKey(name + "-missing")
+		if err := store.Set(ctx, existing, []byte("v1")); err != nil {
+			t.Fatalf("Set while testing CompareAndSwapMany - key=%s: %s", existing, err)
+		}
+		return existing, missing
+	}
+	requireValue := func(t *testing.T, key, expected []byte) {
+		t.Helper()
+		value, err := store.Get(ctx, key)
+		if expected == nil {
+			if !errors.Is(err, kv.ErrNotFound) {
+				t.Fatalf("Get key=%s err=%v, expected err=%s", key, err, kv.ErrNotFound)
+			}
+			return
+		}
+		if err != nil {
+			t.Fatalf("Get key=%s: %s", key, err)
+		}
+		if !bytes.Equal(value, expected) {
+			t.Fatalf("Get key=%s value=%s, expected value=%s", key, value, expected)
+		}
+	}
+
+	t.Run("all_match", func(t *testing.T) {
+		existing, missing := setup(t, "cas-many-match")
+		err := store.CompareAndSwapMany(ctx, []kv.CASOp{
+			{Key: existing, Value: []byte("v2"), Predicate: []byte("v1")},
+			{Key: missing, Value: []byte("new")},
+		})
+		if err != nil {
+			t.Fatalf("CompareAndSwapMany all matching predicates: %s", err)
+		}
+		requireValue(t, existing, []byte("v2"))
+		requireValue(t, missing, []byte("new"))
+	})
+
+	t.Run("one_mismatch", func(t *testing.T) {
+		existing, missing := setup(t, "cas-many-mismatch")
+		mismatch := uniqueKey("cas-many-mismatch-value")
+		if err := store.Set(ctx, mismatch, []byte("changed")); err != nil {
+			t.Fatalf("Set while testing CompareAndSwapMany - key=%s: %s", mismatch, err)
+		}
+		err := store.CompareAndSwapMany(ctx, []kv.CASOp{
+			{Key: existing, Value: []byte("v2"), Predicate: []byte("v1")},
+			{Key: missing, Value: []byte("new")},
+			{Key: mismatch, Value: []byte("v2"), Predicate: []byte("v1")},
+		})
+		if !errors.Is(err, kv.ErrPredicateFailed) {
+			t.Fatalf("CompareAndSwapMany err=%v, expected err=%s", err, kv.ErrPredicateFailed)
+		}
+		if !strings.Contains(err.Error(), string(mismatch)) {
+			t.Fatalf("CompareAndSwapMany err=%v, expected the failing key '%s'", err, mismatch)
+		}
+		// nothing was applied
+		requireValue(t, existing, []byte("v1"))
+		requireValue(t, missing, nil)
+		requireValue(t, mismatch, []byte("changed"))
+	})
+
+	t.Run("missing_argument", func(t *testing.T) {
+		err := store.CompareAndSwapMany(ctx, []kv.CASOp{{Key: nil, Value: []byte("v")}})
+		if !errors.Is(err, kv.ErrMissingKey) {
+			t.Fatalf("CompareAndSwapMany using nil key err=%v, expected %s", err, kv.ErrMissingKey)
+		}
+		err = store.CompareAndSwapMany(ctx, []kv.CASOp{{Key: uniqueKey("cas-many-nil-value")}})
+		if !errors.Is(err, kv.ErrMissingValue) {
+			t.Fatalf("CompareAndSwapMany using nil value err=%v, expected %s", err, kv.ErrMissingValue)
+		}
+	})
+
+	t.Run("duplicate_key", func(t *testing.T) {
+		existing, missing := setup(t, "cas-many-duplicate")
+		err := store.CompareAndSwapMany(ctx, []kv.CASOp{
+			{Key: missing, Value: []byte("new")},
+			{Key: existing, Value: []byte("v2"), Predicate: []byte("v1")},
+			{Key: existing, Value: []byte("v3"), Predicate: []byte("v2")},
+		})
+		if !errors.Is(err, kv.ErrDuplicateKey) {
+			t.Fatalf("CompareAndSwapMany with a duplicate key err=%v, expected %s", err, kv.ErrDuplicateKey)
+		}
+		// nothing was applied
+		requireValue(t, existing, []byte("v1"))
+		requireValue(t, missing, nil)
+	})
+}
+
+func testStoreSetIfFunc(t *testing.T, ms MakeStore) {
+	ctx := context.Background()
+	store := ms(t, ctx)
+	defer store.Close()
+
+	createOnly := func(current []byte) bool { return current == nil }
+
+	t.Run("create_only", func(t *testing.T) {
+		key := uniqueKey("set-if-func-create")
+		val1 := []byte("v1")
+		if err := store.SetIfFunc(ctx, key, val1, createOnly); err != nil {
+			t.Fatalf("SetIfFunc create only without previous key - key=%s value=%s: %s", key, val1, err)
+		}
+		val2 := []byte("v2")
+		err := store.SetIfFunc(ctx, key, val2, createOnly)
+		if !errors.Is(err, kv.ErrPredicateFailed) {
+			t.Fatalf("SetIfFunc create only err=%v - key=%s, value=%s, expected err=%s", err, key, val2, kv.ErrPredicateFailed)
+		}
+		value, err := store.Get(ctx, key)
+		if err != nil {
+			t.Fatalf("Get key=%s: %s", key, err)
+		}
+		if !bytes.Equal(value, val1) {
+			t.Fatalf("Get key=%s value=%s, expected value=%s", key, value, val1)
+		}
+	})
+
+	t.Run("accept", func(t *testing.T) {
+		key := uniqueKey("set-if-func-accept")
+		val1 := []byte("version:1;data")
+		if err := store.Set(ctx, key, val1); err != nil {
+			t.Fatalf("Set while testing SetIfFunc - key=%s value=%s: %s", key, val1, err)
+		}
+		var predicateValue []byte
+		val2 := []byte("version:2;data")
+		err := store.SetIfFunc(ctx, key, val2, func(current []byte) bool {
+			predicateValue = current
+			return bytes.HasPrefix(current, []byte("version:1;"))
+		})
+		if err != nil {
+			t.Fatalf("SetIfFunc accepted predicate - key=%s value=%s: %s", key, val2, err)
+		}
+		if !bytes.Equal(predicateValue, val1) {
+			t.Fatalf("SetIfFunc predicate called with current=%s, expected %s", predicateValue, val1)
+		}
+		value, err := store.Get(ctx, key)
+		if err != nil {
+			t.Fatalf("Get key=%s: %s", key, err)
+		}
+		if !bytes.Equal(value, val2) {
+			t.Fatalf("Get key=%s value=%s, expected value=%s", key, value, val2)
+		}
+	})
+
+	t.Run("reject", func(t *testing.T) {
+		key := uniqueKey("set-if-func-reject")
+		val1 := []byte("v1")
+		if err := store.Set(ctx, key, val1); err != nil {
+			t.Fatalf("Set while testing SetIfFunc - key=%s value=%s: %s", key, val1, err)
+		}
+		val2 := []byte("v2")
+		err := store.SetIfFunc(ctx, key, val2, func([]byte) bool { return false })
+		if !errors.Is(err, kv.ErrPredicateFailed) {
+			t.Fatalf("SetIfFunc err=%v - key=%s, value=%s, expected err=%s", err, key, val2, kv.ErrPredicateFailed)
+		}
+		value, err := store.Get(ctx, key)
+		if err != nil {
+			t.Fatalf("Get key=%s: %s", key, err)
+		}
+		if !bytes.Equal(value, val1) {
+			t.Fatalf("Get key=%s value=%s after rejected SetIfFunc, expected value=%s", key, value, val1)
+		}
+	})
+
+	t.Run("missing_key_reject", func(t *testing.T) {
+		key := uniqueKey("set-if-func-missing")
+		err := store.SetIfFunc(ctx, key, []byte("v"), func(current []byte) bool { return current != nil })
+		if !errors.Is(err, kv.ErrPredicateFailed) {
+			t.Fatalf("SetIfFunc err=%v - key=%s, expected err=%s", err, key, kv.ErrPredicateFailed)
+		}
+		if _, err := store.Get(ctx, key); !errors.Is(err, kv.ErrNotFound) {
+			t.Fatalf("Get key=%s err=%v after rejected SetIfFunc, expected err=%s", key, err, kv.ErrNotFound)
+		}
+	})
+}
+
+func testStoreGetOrSet(t *testing.T, ms MakeStore) {
+	ctx := context.Background()
+	store := ms(t, ctx)
+	defer store.Close()
+
+	t.Run("existing", func(t *testing.T) {
+		key := uniqueKey("get-or-set-existing")
+		val1 := []byte("v1")
+		if err := store.Set(ctx, key, val1); err != nil {
+			t.Fatalf("Set while testing GetOrSet - key=%s value=%s: %s", key, val1, err)
+		}
+		value, created, err := store.GetOrSet(ctx, key, []byte("default"))
+		if err != nil {
+			t.Fatalf("GetOrSet existing key=%s: %s", key, err)
+		}
+		if created || !bytes.Equal(value, val1) {
+			t.Fatalf("GetOrSet existing key=%s value=%s created=%t, expected value=%s created=false", key, value, created, val1)
+		}
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		key := uniqueKey("get-or-set-missing")
+		defaultValue := []byte("default")
+		value, created, err := store.GetOrSet(ctx, key, defaultValue)
+		if err != nil {
+			t.Fatalf("GetOrSet missing key=%s: %s", key, err)
+		}
+		if !created || !bytes.Equal(value, defaultValue) {
+			t.Fatalf("GetOrSet missing key=%s value=%s created=%t, expected value=%s created=true", key, value, created, defaultValue)
+		}
+		value, err = store.Get(ctx, key)
+		if err != nil {
+			t.Fatalf("Get key=%s: %s", key, err)
+		}
+		if !bytes.Equal(value, defaultValue) {
+			t.Fatalf("Get key=%s value=%s, expected value=%s", key, value, defaultValue)
+		}
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		const callers = 10
+		key := uniqueKey("get-or-set-concurrent")
+		values := make([][]byte, callers)
+		createdBy := make([]bool, callers)
+		var g errgroup.Group
+		for i := 0; i < callers; i++ {
+			i := i
+			g.Go(func() error {
+				var err error
+				values[i], createdBy[i], err = store.GetOrSet(ctx, key, []byte("default-"+strconv.Itoa(i)))
+				return err
+			})
+		}
+		if err := g.Wait(); err != nil {
+			t.Fatalf("concurrent GetOrSet key=%s: %s", key, err)
+		}
+		creator := -1
+		for i, created := range createdBy {
+			if !created {
+				continue
+			}
+			if creator != -1 {
+				t.Fatalf("concurrent GetOrSet key=%s created by both caller %d and %d", key, creator, i)
+			}
+			creator = i
+		}
+		if creator == -1 {
+			t.Fatalf("concurrent GetOrSet key=%s not created by any caller", key)
+		}
+		expected := []byte("default-" + strconv.Itoa(creator))
+		for i, value := range values {
+			if !bytes.Equal(value, expected) {
+				t.Errorf("concurrent GetOrSet caller %d value=%s, expected value=%s of the creating caller", i, value, expected)
+			}
+		}
+	})
+
+	t.Run("missing_value", func(t *testing.T) {
+		_, _, err := store.GetOrSet(ctx, uniqueKey("get-or-set-missing-value"), nil)
+		if !errors.Is(err, kv.ErrMissingValue) {
+			t.Fatalf("GetOrSet without default value err=%v, expected %s", err, kv.ErrMissingValue)
+		}
+	})
+}
+
 func testStoreScan(t *testing.T, ms MakeStore) {
 	ctx := context.Background()
 	store := ms(t, ctx)
@@ -278,6 +1050,166 @@ func testStoreScan(t *testing.T, ms MakeStore) {
 	})
 }
 
+func testStoreScanSnapshot(t *testing.T, ms MakeStore) {
+	ctx := context.Background()
+	store := ms(t, ctx)
+	defer store.Close()
+
+	samplePrefix := uniqueKey("scan-snapshot")
+	const sampleItems = 20
+	sampleData := setupSampleData(t, ctx, store, string(samplePrefix), sampleItems)
+
+	scan, err := store.ScanSnapshot(ctx, samplePrefix)
+	if err != nil {
+		t.Fatal("failed to scan snapshot", err)
+	}
+	defer scan.Close()
+
+	var entries []kv.Entry
+	if !scan.Next() {
+		t.Fatal("scan snapshot got no entries", scan.Err())
+	}
+	entries = append(entries, *scan.Entry())
+
+	// concurrent writer adds keys and updates values while the snapshot is iterated
+	var g errgroup.Group
+	g.Go(func() error {
+		for i := 0; i < sampleItems; i++ {
+			added := sampleEntry(string(samplePrefix), sampleItems+i)
+			if err := store.Set(ctx, added.Key, added.Value); err != nil {
+				return err
+			}
+			if err := store.Set(ctx, sampleData[i].Key, []byte("updated")); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err := g.Wait(); err != nil {
+		t.Fatal("failed to write while scanning snapshot", err)
+	}
+
+	for scan.Next() {
+		ent := scan.Entry()
+		if !bytes.HasPrefix(ent.Key, samplePrefix) {
+			break
+		}
+		entries = append(entries, *ent)
+	}
+	if err := scan.Err(); err != nil {
+		t.Fatal("scan snapshot ended with an error", err)
+	}
+	if diff := deep.Equal(entries, sampleData); diff != nil {
+		t.Fatal("scan snapshot data didn't match:", diff)
+	}
+
+	// a new scan includes the writes
+	if entries := scanPrefixEntries(t, ctx, store, samplePrefix); len(entries) != 2*sampleItems {
+		t.Fatalf("scan after writes got %d entries, expected %d", len(entries), 2*sampleItems)
+	}
+}
+
+// BenchmarkListKeys compares listing keys with large values using ListKeys with scanning them
+func BenchmarkListKeys(b *testing.B, name, dsn string) {
+	ctx := context.Background()
+	store, err := kv.Open(ctx, name, dsn)
+	if err != nil {
+		b.Fatalf("failed to open kv '%s' (%s) store: %s", name, dsn, err)
+	}
+	defer store.Close()
+
+	const (
+		items     = 100
+		valueSize = 64 * 1024
+	)
+	prefix := uniqueKey("bench-list-keys")
+	value := bytes.Repeat([]byte("v"), valueSize)
+	for i := 0; i < items; i++ {
+		key := []byte(fmt.Sprintf("%s-%04d", prefix, i))
+		if err := store.Set(ctx, key, value); err != nil {
+			b.Fatalf("failed to setup data with key '%s': %s", key, err)
+		}
+	}
+
+	b.Run("list_keys", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			iter, err := store.ListKeys(ctx, prefix)
+			if err != nil {
+				b.Fatalf("ListKeys: %s", err)
+			}
+			for iter.Next() {
+			}
+			if err := iter.Err(); err != nil {
+				b.Fatalf("ListKeys: %s", err)
+			}
+			iter.Close()
+		}
+	})
+
+	b.Run("scan", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			iter, err := kv.ScanPrefix(ctx, store, prefix)
+			if err != nil {
+				b.Fatalf("ScanPrefix: %s", err)
+			}
+			for iter.Next() {
+			}
+			if err := iter.Err(); err != nil {
+				b.Fatalf("ScanPrefix: %s", err)
+			}
+			iter.Close()
+		}
+	})
+}
+
+// BenchmarkDeleteBatch compares deleting keys using DeleteBatch with deleting the same keys one by one
+func BenchmarkDeleteBatch(b *testing.B, name, dsn string) {
+	ctx := context.Background()
+	store, err := kv.Open(ctx, name, dsn)
+	if err != nil {
+		b.Fatalf("failed to open kv '%s' (%s) store: %s", name, dsn, err)
+	}
+	defer store.Close()
+
+	const batchSize = 100
+	setup := func(b *testing.B, prefix string) [][]byte {
+		b.Helper()
+		keys := make([][]byte, 0, batchSize)
+		for i := 0; i < batchSize; i++ {
+			entry := sampleEntry(prefix, i)
+			if err := store.Set(ctx, entry.Key, entry.Value); err != nil {
+				b.Fatalf("failed to setup data with '%s': %s", entry, err)
+			}
+			keys = append(keys, entry.Key)
+		}
+		return keys
+	}
+
+	b.Run("batch", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			b.StopTimer()
+			keys := setup(b, string(uniqueKey("bench-delete-batch")))
+			b.StartTimer()
+			if err := store.DeleteBatch(ctx, keys); err != nil {
+				b.Fatalf("DeleteBatch: %s", err)
+			}
+		}
+	})
+
+	b.Run("loop", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			b.StopTimer()
+			keys := setup(b, string(uniqueKey("bench-delete-loop")))
+			b.StartTimer()
+			for _, key := range keys {
+				if err := store.Delete(ctx, key); err != nil {
+					b.Fatalf("Delete: %s", err)
+				}
+			}
+		}
+	})
+}
+
 func MakeStoreByName(name, dsn string) MakeStore {
 	return func(t *testing.T, ctx context.Context) kv.Store {
 		t.Helper()
@@ -375,6 +1307,74 @@ func testScanPrefix(t *testing.T, ms MakeStore) {
 	}
 }
 
+// testScanWhileWriting scans a prefix using ScanSnapshot while keys are inserted in between the scanned keys. The
+// scan must return exactly the keys that existed before it started, in order and without duplicates.
+func testScanWhileWriting(t *testing.T, ms MakeStore) {
+	const items = 500
+	ctx := context.Background()
+	store := ms(t, ctx)
+	defer store.Close()
+
+	prefix := string(uniqueKey("scan-while-writing"))
+	initial := make(map[string]bool, items)
+	for i := 0; i < items; i++ {
+		entry := sampleEntry(prefix, i*2)
+		if err := store.Set(ctx, entry.Key, entry.Value); err != nil {
+			t.Fatalf("failed to setup data with '%s': %s", entry, err)
+		}
+		initial[string(entry.Key)] = true
+	}
+
+	iter, err := store.ScanSnapshot(ctx, []byte(prefix))
+	if err != nil {
+		t.Fatalf("ScanSnapshot '%s': %s", prefix, err)
+	}
+	scan := &kv.PrefixIterator{Iterator: iter, Prefix: []byte(prefix)}
+	defer scan.Close()
+
+	// insert the odd keys, in between the scanned ones, once the scan returned its first entry
+	scanStarted := make(chan struct{})
+	writeErr := make(chan error, 1)
+	go func() {
+		defer close(writeErr)
+		<-scanStarted
+		for i := 0; i < items; i++ {
+			entry := sampleEntry(prefix, i*2+1)
+			if err := store.Set(ctx, entry.Key, entry.Value); err != nil {
+				writeErr <- err
+				return
+			}
+		}
+	}()
+
+	var lastKey []byte
+	seen := make(map[string]bool, items)
+	for scan.Next() {
+		key := scan.Entry().Key
+		if lastKey == nil {
+			// let the writer run to completion while the scan is in progress
+			close(scanStarted)
+			if err := <-writeErr; err != nil {
+				t.Fatalf("Set while scanning: %s", err)
+			}
+		}
+		if lastKey != nil && bytes.Compare(lastKey, key) >= 0 {
+			t.Fatalf("scan key '%s' after '%s', expected increasing keys without duplicates", key, lastKey)
+		}
+		lastKey = key
+		seen[string(key)] = true
+	}
+	if err := scan.Err(); err != nil {
+		t.Fatalf("ScanSnapshot '%s' ended with an error: %s", prefix, err)
+	}
+	if lastKey == nil {
+		t.Fatalf("ScanSnapshot '%s' returned no entries", prefix)
+	}
+	if diff := deep.Equal(seen, initial); diff != nil {
+		t.Fatal("scanned keys didn't match the keys before the scan:", diff)
+	}
+}
+
 func testDeleteWhileIterPrefix(t *testing.T, ms MakeStore) {
 	// iteration
 	//            deletion
@@ -0,0 +1,131 @@
+package kv
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrLocked    = errors.New("locked")
+	ErrLeaseLost = errors.New("lease lost")
+)
+
+// leaseValue is the value of a lock key, the holder of the lease and the time it expires
+type leaseValue struct {
+	Holder    string    `json:"holder"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Lease is held by the caller that locked a key, until it expires or is released.
+// Expiration is checked using the local clock of the lockers, the ttl should be well above the expected clock skew.
+type Lease interface {
+	// Renew extends the lease by its ttl from now. Fails with ErrLeaseLost if the lease expired and was reclaimed,
+	// or was released.
+	Renew(ctx context.Context) error
+	// Release ends the lease by deleting the lock key, so the key can be locked right away. Only the holder can
+	// release the lease, fails with ErrLeaseLost if the lease expired and was reclaimed, or was already released.
+	Release(ctx context.Context) error
+}
+
+// lease is the Lease of a lock key, holding the value it last set
+type lease struct {
+	store    Store
+	key      []byte
+	value    []byte
+	holder   string
+	ttl      time.Duration
+	released bool
+}
+
+// Lock creates a lease on key for ttl, using store SetIf so that a single caller holds the lease at a time.
+// An expired lease is reclaimed. Fails with ErrLocked while another caller holds the lease.
+func Lock(ctx context.Context, store Store, key []byte, ttl time.Duration) (Lease, error) {
+	l := &lease{
+		store:  store,
+		key:    key,
+		holder: uuid.New().String(),
+		ttl:    ttl,
+	}
+	value, err := l.newValue()
+	if err != nil {
+		return nil, err
+	}
+	err = store.SetIf(ctx, key, value, nil)
+	if errors.Is(err, ErrPredicateFailed) {
+		// reclaim the lease in case it expired
+		var curr []byte
+		curr, err = store.Get(ctx, key)
+		if errors.Is(err, ErrNotFound) {
+			// released and deleted since, retry as a new lease
+			err = store.SetIf(ctx, key, value, nil)
+		} else if err == nil {
+			var held leaseValue
+			if err := json.Unmarshal(curr, &held); err != nil {
+				return nil, fmt.Errorf("lock %s: %w", key, err)
+			}
+			if time.Now().Before(held.ExpiresAt) {
+				return nil, fmt.Errorf("%w: %s", ErrLocked, key)
+			}
+			err = store.SetIf(ctx, key, value, curr)
+		}
+	}
+	if errors.Is(err, ErrPredicateFailed) {
+		// another caller locked or reclaimed the lease first
+		return nil, fmt.Errorf("%w: %s", ErrLocked, key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("lock %s: %w", key, err)
+	}
+	l.value = value
+	return l, nil
+}
+
+func (l *lease) newValue() ([]byte, error) {
+	return json.Marshal(leaseValue{
+		Holder:    l.holder,
+		ExpiresAt: time.Now().Add(l.ttl),
+	})
+}
+
+func (l *lease) Renew(ctx context.Context) error {
+	if l.released {
+		return fmt.Errorf("%w: %s released", ErrLeaseLost, l.key)
+	}
+	value, err := l.newValue()
+	if err != nil {
+		return err
+	}
+	err = l.store.SetIf(ctx, l.key, value, l.value)
+	if err := leaseErr(l.key, err); err != nil {
+		return err
+	}
+	l.value = value
+	return nil
+}
+
+func (l *lease) Release(ctx context.Context) error {
+	if l.released {
+		return fmt.Errorf("%w: %s released", ErrLeaseLost, l.key)
+	}
+	if err := leaseErr(l.key, l.store.DeleteIf(ctx, l.key, l.value)); err != nil {
+		return err
+	}
+	l.released = true
+	return nil
+}
+
+// leaseErr returns the error of an operation conditioned on the lease value, the lease is lost if the value changed
+func leaseErr(key []byte, err error) error {
+	if errors.Is(err, ErrPredicateFailed) {
+		return fmt.Errorf("%w: %s", ErrLeaseLost, key)
+	}
+	if err != nil {
+		return fmt.Errorf("lease %s: %w", key, err)
+	}
+	return nil
+}
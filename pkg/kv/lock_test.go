@@ -0,0 +1,137 @@
+package kv_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/treeverse/lakefs/pkg/kv"
+	"github.com/treeverse/lakefs/pkg/kv/mem"
+)
+
+func openLockTestStore(t *testing.T) kv.Store {
+	t.Helper()
+	store, err := (&mem.Driver{}).Open(context.Background(), "")
+	if err != nil {
+		t.Fatalf("open mem store: %s", err)
+	}
+	t.Cleanup(store.Close)
+	return store
+}
+
+func TestLockMutualExclusion(t *testing.T) {
+	ctx := context.Background()
+	store := openLockTestStore(t)
+	key := []byte("lock/job")
+
+	const (
+		lockers    = 2
+		iterations = 50
+	)
+	var (
+		holders  int32
+		acquired int32
+		wg       sync.WaitGroup
+	)
+	errCh := make(chan error, lockers)
+	for i := 0; i < lockers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < iterations; j++ {
+				lease, err := kv.Lock(ctx, store, key, time.Minute)
+				if errors.Is(err, kv.ErrLocked) {
+					continue
+				}
+				if err != nil {
+					errCh <- err
+					return
+				}
+				atomic.AddInt32(&acquired, 1)
+				if n := atomic.AddInt32(&holders, 1); n != 1 {
+					errCh <- errors.New("lease held by more than one locker")
+					return
+				}
+				atomic.AddInt32(&holders, -1)
+				if err := lease.Release(ctx); err != nil {
+					errCh <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errCh)
+	for err := range errCh {
+		t.Fatal(err)
+	}
+	if acquired == 0 {
+		t.Fatal("lock was never acquired")
+	}
+}
+
+func TestLockExpiredReclaim(t *testing.T) {
+	ctx := context.Background()
+	store := openLockTestStore(t)
+	key := []byte("lock/job")
+	const ttl = 50 * time.Millisecond
+
+	first, err := kv.Lock(ctx, store, key, ttl)
+	if err != nil {
+		t.Fatalf("Lock: %s", err)
+	}
+	if _, err := kv.Lock(ctx, store, key, ttl); !errors.Is(err, kv.ErrLocked) {
+		t.Fatalf("Lock while held err=%v, expected %s", err, kv.ErrLocked)
+	}
+
+	time.Sleep(2 * ttl)
+	second, err := kv.Lock(ctx, store, key, time.Minute)
+	if err != nil {
+		t.Fatalf("Lock expired lease: %s", err)
+	}
+	if err := first.Release(ctx); !errors.Is(err, kv.ErrLeaseLost) {
+		t.Fatalf("Release by non holder err=%v, expected %s", err, kv.ErrLeaseLost)
+	}
+	if err := first.Renew(ctx); !errors.Is(err, kv.ErrLeaseLost) {
+		t.Fatalf("Renew by non holder err=%v, expected %s", err, kv.ErrLeaseLost)
+	}
+	if _, err := kv.Lock(ctx, store, key, ttl); !errors.Is(err, kv.ErrLocked) {
+		t.Fatalf("Lock after non holder release err=%v, expected %s", err, kv.ErrLocked)
+	}
+	if err := second.Release(ctx); err != nil {
+		t.Fatalf("Release: %s", err)
+	}
+	if _, err := store.Get(ctx, key); !errors.Is(err, kv.ErrNotFound) {
+		t.Fatalf("Get lock key after release err=%v, expected %s", err, kv.ErrNotFound)
+	}
+	if err := second.Release(ctx); !errors.Is(err, kv.ErrLeaseLost) {
+		t.Fatalf("second Release err=%v, expected %s", err, kv.ErrLeaseLost)
+	}
+	if _, err := kv.Lock(ctx, store, key, ttl); err != nil {
+		t.Fatalf("Lock after release: %s", err)
+	}
+}
+
+func TestLockRenew(t *testing.T) {
+	ctx := context.Background()
+	store := openLockTestStore(t)
+	key := []byte("lock/job")
+	const ttl = 100 * time.Millisecond
+
+	lease, err := kv.Lock(ctx, store, key, ttl)
+	if err != nil {
+		t.Fatalf("Lock: %s", err)
+	}
+	time.Sleep(ttl / 2)
+	if err := lease.Renew(ctx); err != nil {
+		t.Fatalf("Renew: %s", err)
+	}
+	// past the original ttl, within the renewed one
+	time.Sleep(ttl * 3 / 4)
+	if _, err := kv.Lock(ctx, store, key, ttl); !errors.Is(err, kv.ErrLocked) {
+		t.Fatalf("Lock after renew err=%v, expected %s", err, kv.ErrLocked)
+	}
+}
@@ -3,7 +3,9 @@ package mem
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"sort"
+	"strings"
 	"sync"
 
 	"github.com/treeverse/lakefs/pkg/kv"
@@ -24,6 +26,21 @@ type EntriesIterator struct {
 	store *Store
 }
 
+// snapshotIterator iterates entries copied from the store
+type snapshotIterator struct {
+	entries []kv.Entry
+	entry   *kv.Entry
+	err     error
+}
+
+type KeysIterator struct {
+	key    []byte
+	err    error
+	start  []byte
+	prefix []byte
+	store  *Store
+}
+
 const DriverName = "mem"
 
 //nolint:gochecknoinits
@@ -101,23 +118,188 @@ func (s *Store) SetIf(_ context.Context, key, value, valuePredicate []byte) erro
 	return nil
 }
 
+func (s *Store) CompareAndSwapMany(_ context.Context, ops []kv.CASOp) error {
+	if err := kv.ValidateCASOps(ops); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, op := range ops {
+		curr, currOK := s.m[string(op.Key)]
+		if (op.Predicate == nil && currOK) || (op.Predicate != nil && (!currOK || !bytes.Equal(op.Predicate, curr))) {
+			return fmt.Errorf("%w: key %s", kv.ErrPredicateFailed, op.Key)
+		}
+	}
+	for _, op := range ops {
+		if _, ok := s.m[string(op.Key)]; !ok {
+			s.insertNewKey(op.Key)
+		}
+		s.m[string(op.Key)] = op.Value
+	}
+	return nil
+}
+
+func (s *Store) SetIfFunc(_ context.Context, key, value []byte, pred func(current []byte) bool) error {
+	if key == nil {
+		return kv.ErrMissingKey
+	}
+	if value == nil {
+		return kv.ErrMissingValue
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	curr, currOK := s.m[string(key)]
+	if !pred(curr) {
+		return kv.ErrPredicateFailed
+	}
+	if !currOK {
+		s.insertNewKey(key)
+	}
+	s.m[string(key)] = value
+	return nil
+}
+
+func (s *Store) GetOrSet(_ context.Context, key, defaultValue []byte) ([]byte, bool, error) {
+	if key == nil {
+		return nil, false, kv.ErrMissingKey
+	}
+	if defaultValue == nil {
+		return nil, false, kv.ErrMissingValue
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if curr, ok := s.m[string(key)]; ok {
+		return curr, false, nil
+	}
+	s.insertNewKey(key)
+	s.m[string(key)] = defaultValue
+	return defaultValue, true, nil
+}
+
 func (s *Store) Delete(_ context.Context, key []byte) error {
 	if key == nil {
 		return kv.ErrMissingKey
 	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	s.deleteKey(key)
+	return nil
+}
+
+// deleteKey removes key from the store, caller must hold the store lock
+func (s *Store) deleteKey(key []byte) {
 	if _, found := s.m[string(key)]; !found {
-		return nil
+		return
 	}
 	idx := sort.SearchStrings(s.keys, string(key))
 	if idx < len(s.keys) && s.keys[idx] == string(key) {
 		s.keys = append(s.keys[:idx], s.keys[idx+1:]...)
 	}
 	delete(s.m, string(key))
+}
+
+func (s *Store) DeleteIf(_ context.Context, key, valuePredicate []byte) error {
+	if key == nil {
+		return kv.ErrMissingKey
+	}
+	if valuePredicate == nil {
+		return kv.ErrMissingValue
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	curr, ok := s.m[string(key)]
+	if !ok || !bytes.Equal(curr, valuePredicate) {
+		return kv.ErrPredicateFailed
+	}
+	s.deleteKey(key)
 	return nil
 }
 
+func (s *Store) DeleteBatch(_ context.Context, keys [][]byte) error {
+	for _, key := range keys {
+		if key == nil {
+			return kv.ErrMissingKey
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, key := range keys {
+		s.deleteKey(key)
+	}
+	return nil
+}
+
+func (s *Store) DeletePrefix(_ context.Context, prefix []byte, deleteAll bool) (int64, error) {
+	if len(prefix) == 0 && !deleteAll {
+		return 0, kv.ErrMissingKey
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	start := sort.SearchStrings(s.keys, string(prefix))
+	end := start
+	for end < len(s.keys) && strings.HasPrefix(s.keys[end], string(prefix)) {
+		delete(s.m, s.keys[end])
+		end++
+	}
+	s.keys = append(s.keys[:start], s.keys[end:]...)
+	return int64(end - start), nil
+}
+
+func (s *Store) Rename(_ context.Context, oldKey, newKey []byte, overwrite bool) error {
+	if oldKey == nil || newKey == nil {
+		return kv.ErrMissingKey
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	value, ok := s.m[string(oldKey)]
+	if !ok {
+		return kv.ErrNotFound
+	}
+	if bytes.Equal(oldKey, newKey) {
+		return nil
+	}
+	if _, exists := s.m[string(newKey)]; exists && !overwrite {
+		return fmt.Errorf("%w: key %s exists", kv.ErrPredicateFailed, newKey)
+	}
+	s.deleteKey(oldKey)
+	s.setKey(newKey, value)
+	return nil
+}
+
+func (s *Store) RenamePrefix(_ context.Context, oldPrefix, newPrefix []byte, overwrite bool) (int64, error) {
+	if err := kv.ValidateRenamePrefix(oldPrefix, newPrefix); err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var keys []string
+	for idx := sort.SearchStrings(s.keys, string(oldPrefix)); idx < len(s.keys) && strings.HasPrefix(s.keys[idx], string(oldPrefix)); idx++ {
+		keys = append(keys, s.keys[idx])
+	}
+	if !overwrite {
+		for _, key := range keys {
+			newKey := kv.RenamedKey([]byte(key), oldPrefix, newPrefix)
+			if _, exists := s.m[string(newKey)]; exists {
+				return 0, fmt.Errorf("%w: key %s exists", kv.ErrPredicateFailed, newKey)
+			}
+		}
+	}
+	for _, key := range keys {
+		value := s.m[key]
+		s.deleteKey([]byte(key))
+		s.setKey(kv.RenamedKey([]byte(key), oldPrefix, newPrefix), value)
+	}
+	return int64(len(keys)), nil
+}
+
+// setKey sets the value of key, caller must hold the store lock
+func (s *Store) setKey(key, value []byte) {
+	if _, found := s.m[string(key)]; !found {
+		s.insertNewKey(key)
+	}
+	s.m[string(key)] = value
+}
+
 func (s *Store) Scan(_ context.Context, start []byte) (kv.EntriesIterator, error) {
 	return &EntriesIterator{
 		store: s,
@@ -125,6 +307,54 @@ func (s *Store) Scan(_ context.Context, start []byte) (kv.EntriesIterator, error
 	}, nil
 }
 
+func (s *Store) ScanSnapshot(_ context.Context, start []byte) (kv.EntriesIterator, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	idx := sort.SearchStrings(s.keys, string(start))
+	entries := make([]kv.Entry, 0, len(s.keys)-idx)
+	for _, key := range s.keys[idx:] {
+		entries = append(entries, kv.Entry{Key: []byte(key), Value: s.m[key]})
+	}
+	return &snapshotIterator{entries: entries}, nil
+}
+
+func (s *Store) GetRange(_ context.Context, prefix []byte, limit int) (map[string][]byte, error) {
+	limit = kv.GetRangeLimit(limit)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	res := make(map[string][]byte)
+	for idx := sort.SearchStrings(s.keys, string(prefix)); idx < len(s.keys) && len(res) < limit; idx++ {
+		key := s.keys[idx]
+		if !strings.HasPrefix(key, string(prefix)) {
+			break
+		}
+		res[key] = s.m[key]
+	}
+	return res, nil
+}
+
+func (s *Store) ListKeys(_ context.Context, prefix []byte) (kv.KeysIterator, error) {
+	start := prefix
+	if start == nil {
+		start = []byte{}
+	}
+	return &KeysIterator{
+		store:  s,
+		start:  start,
+		prefix: prefix,
+	}, nil
+}
+
+// Flush returns immediately, writes are not buffered
+func (s *Store) Flush(_ context.Context) error {
+	return nil
+}
+
+// Clone returns the same store, as the in-memory store has no resources to release on Close
+func (s *Store) Clone() kv.Store {
+	return s
+}
+
 func (s *Store) Close() {}
 
 func (e *EntriesIterator) Next() bool {
@@ -170,3 +400,64 @@ func (e *EntriesIterator) Err() error {
 func (e *EntriesIterator) Close() {
 	e.err = kv.ErrClosedEntries
 }
+
+func (e *snapshotIterator) Next() bool {
+	if e.err != nil || len(e.entries) == 0 {
+		e.entry = nil
+		return false
+	}
+	e.entry = &e.entries[0]
+	e.entries = e.entries[1:]
+	return true
+}
+
+func (e *snapshotIterator) Entry() *kv.Entry {
+	return e.entry
+}
+
+func (e *snapshotIterator) Err() error {
+	return e.err
+}
+
+func (e *snapshotIterator) Close() {
+	e.entries = nil
+	e.err = kv.ErrClosedEntries
+}
+
+func (k *KeysIterator) Next() bool {
+	if k.err != nil {
+		return false
+	}
+	if k.start == nil {
+		k.key = nil
+		return false
+	}
+	k.store.mu.RLock()
+	defer k.store.mu.RUnlock()
+	idx := sort.SearchStrings(k.store.keys, string(k.start))
+	if idx == len(k.store.keys) || !strings.HasPrefix(k.store.keys[idx], string(k.prefix)) {
+		k.start = nil
+		k.key = nil
+		return false
+	}
+	k.key = []byte(k.store.keys[idx])
+	// set start to the next item - nil as end indicator
+	if idx+1 < len(k.store.keys) {
+		k.start = []byte(k.store.keys[idx+1])
+	} else {
+		k.start = nil
+	}
+	return true
+}
+
+func (k *KeysIterator) Key() []byte {
+	return k.key
+}
+
+func (k *KeysIterator) Err() error {
+	return k.err
+}
+
+func (k *KeysIterator) Close() {
+	k.err = kv.ErrClosedEntries
+}
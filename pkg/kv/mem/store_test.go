@@ -1,12 +1,82 @@
 package mem_test
 
 import (
+	"context"
 	"testing"
+	"time"
 
+	"github.com/treeverse/lakefs/pkg/kv"
 	"github.com/treeverse/lakefs/pkg/kv/kvtest"
-	_ "github.com/treeverse/lakefs/pkg/kv/mem"
+	"github.com/treeverse/lakefs/pkg/kv/mem"
 )
 
 func TestMemKV(t *testing.T) {
 	kvtest.TestDriver(t, "mem", "")
 }
+
+func TestMemPoolStats(t *testing.T) {
+	ctx := context.Background()
+	store, err := kv.Open(ctx, mem.DriverName, "")
+	if err != nil {
+		t.Fatalf("failed to open store: %s", err)
+	}
+	defer store.Close()
+	stats, err := kv.GetPoolStats(store)
+	if err != nil {
+		t.Fatalf("GetPoolStats: %s", err)
+	}
+	if stats != (kv.PoolStatsSnapshot{}) {
+		t.Fatalf("pool stats %+v, expected empty snapshot for a store without a pool", stats)
+	}
+}
+
+func TestMemCompact(t *testing.T) {
+	ctx := context.Background()
+	store, err := kv.Open(ctx, mem.DriverName, "")
+	if err != nil {
+		t.Fatalf("failed to open store: %s", err)
+	}
+	defer store.Close()
+	if err := kv.Compact(ctx, kv.NewTimeoutStore(store, time.Minute)); err != nil {
+		t.Fatalf("Compact: %s", err)
+	}
+}
+
+func TestMemDeletePrefixAll(t *testing.T) {
+	ctx := context.Background()
+	store, err := kv.Open(ctx, mem.DriverName, "")
+	if err != nil {
+		t.Fatalf("failed to open store: %s", err)
+	}
+	defer store.Close()
+
+	keys := []string{"a", "b/1", "b/2", "c"}
+	for _, k := range keys {
+		if err := store.Set(ctx, []byte(k), []byte("value")); err != nil {
+			t.Fatalf("failed to set key '%s': %s", k, err)
+		}
+	}
+	deleted, err := store.DeletePrefix(ctx, nil, true)
+	if err != nil {
+		t.Fatalf("DeletePrefix all: %s", err)
+	}
+	if deleted != int64(len(keys)) {
+		t.Fatalf("DeletePrefix all deleted %d keys, expected %d", deleted, len(keys))
+	}
+	iter, err := store.Scan(ctx, []byte{})
+	if err != nil {
+		t.Fatalf("failed to scan: %s", err)
+	}
+	defer iter.Close()
+	if iter.Next() {
+		t.Fatalf("found entry %s after DeletePrefix all", iter.Entry())
+	}
+}
+
+func BenchmarkMemDeleteBatch(b *testing.B) {
+	kvtest.BenchmarkDeleteBatch(b, mem.DriverName, "")
+}
+
+func BenchmarkMemListKeys(b *testing.B) {
+	kvtest.BenchmarkListKeys(b, mem.DriverName, "")
+}
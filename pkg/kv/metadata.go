@@ -0,0 +1,43 @@
+package kv
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// StoreMetadataPath is the reserved key holding the store metadata
+const StoreMetadataPath = "kv-internal/metadata"
+
+// StoreMetadata describes the data kept by a store, for tools to read without inspecting the data itself
+type StoreMetadata struct {
+	// FormatVersion is the version of the data format the store was last migrated to
+	FormatVersion int `json:"format_version"`
+	// CreatedBy is the version of lakeFS that created the store
+	CreatedBy string `json:"created_by"`
+	// LastMigration is the time the store was last migrated
+	LastMigration time.Time `json:"last_migration"`
+}
+
+// GetStoreMetadata returns the metadata of store, ErrNotFound if none was set
+func GetStoreMetadata(ctx context.Context, store Store) (StoreMetadata, error) {
+	var md StoreMetadata
+	val, err := store.Get(ctx, []byte(StoreMetadataPath))
+	if err != nil {
+		return md, fmt.Errorf("failed on Get (path: %s): %w", StoreMetadataPath, err)
+	}
+	if err := json.Unmarshal(val, &md); err != nil {
+		return md, fmt.Errorf("failed on Unmarshal (path: %s): %w", StoreMetadataPath, err)
+	}
+	return md, nil
+}
+
+// SetStoreMetadata sets the metadata of store, replacing the current metadata
+func SetStoreMetadata(ctx context.Context, store Store, md StoreMetadata) error {
+	val, err := json.Marshal(md)
+	if err != nil {
+		return fmt.Errorf("failed on Marshal (path: %s): %w", StoreMetadataPath, err)
+	}
+	return store.Set(ctx, []byte(StoreMetadataPath), val)
+}
@@ -0,0 +1,41 @@
+package kv_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/treeverse/lakefs/pkg/kv"
+	"github.com/treeverse/lakefs/pkg/kv/mem"
+)
+
+func TestStoreMetadata(t *testing.T) {
+	ctx := context.Background()
+	store, err := (&mem.Driver{}).Open(ctx, "")
+	if err != nil {
+		t.Fatalf("open mem store: %s", err)
+	}
+	defer store.Close()
+
+	_, err = kv.GetStoreMetadata(ctx, store)
+	if !errors.Is(err, kv.ErrNotFound) {
+		t.Fatalf("GetStoreMetadata before set err=%v, expected %s", err, kv.ErrNotFound)
+	}
+
+	md := kv.StoreMetadata{
+		FormatVersion: 1,
+		CreatedBy:     "v0.67.0",
+		LastMigration: time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC),
+	}
+	if err := kv.SetStoreMetadata(ctx, store, md); err != nil {
+		t.Fatalf("SetStoreMetadata: %s", err)
+	}
+	got, err := kv.GetStoreMetadata(ctx, store)
+	if err != nil {
+		t.Fatalf("GetStoreMetadata: %s", err)
+	}
+	if got.FormatVersion != md.FormatVersion || got.CreatedBy != md.CreatedBy || !got.LastMigration.Equal(md.LastMigration) {
+		t.Fatalf("GetStoreMetadata = %+v, expected %+v", got, md)
+	}
+}
@@ -0,0 +1,39 @@
+package kv
+
+import "time"
+
+// PoolStatsSnapshot is a point in time snapshot of a driver connection pool
+type PoolStatsSnapshot struct {
+	// AcquireCount is the number of successful connection acquires from the pool
+	AcquireCount int64
+	// AcquireDuration is the total time spent on successful acquires from the pool
+	AcquireDuration time.Duration
+	// CanceledAcquireCount is the number of acquires cancelled by their context
+	CanceledAcquireCount int64
+	// EmptyAcquireCount is the number of successful acquires that waited for a connection, as none was idle
+	EmptyAcquireCount int64
+	// AcquiredConns is the number of connections currently in use
+	AcquiredConns int32
+	// ConstructingConns is the number of connections currently being established
+	ConstructingConns int32
+	// IdleConns is the number of connections currently idle in the pool
+	IdleConns int32
+	// MaxConns is the maximum size of the pool
+	MaxConns int32
+	// TotalConns is the number of connections currently in the pool, acquired, idle or being established
+	TotalConns int32
+}
+
+// PoolStatsReporter is implemented by stores that use a connection pool
+type PoolStatsReporter interface {
+	PoolStats() (PoolStatsSnapshot, error)
+}
+
+// GetPoolStats returns the store connection pool stats, or an empty snapshot for stores without a pool
+func GetPoolStats(store Store) (PoolStatsSnapshot, error) {
+	r, ok := store.(PoolStatsReporter)
+	if !ok {
+		return PoolStatsSnapshot{}, nil
+	}
+	return r.PoolStats()
+}
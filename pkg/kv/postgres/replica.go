@@ -0,0 +1,112 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/treeverse/lakefs/pkg/kv"
+	"golang.org/x/sync/singleflight"
+)
+
+// replicaLagCheckInterval is how long a measured replica lag is reused before measuring it again
+const replicaLagCheckInterval = time.Second
+
+// replicaLagQueryTimeout bounds the time measuring the replica lag
+const replicaLagQueryTimeout = 5 * time.Second
+
+// querier is the part of the pool used to serve reads
+type querier interface {
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+// replica is a read only pool serving reads that accept staleness
+type replica struct {
+	pool querier
+	// lag measures how far behind the primary the replica is
+	lag   func(ctx context.Context) (time.Duration, error)
+	close func()
+	now   func() time.Time
+
+	// measure shares a single lag query between the readers waiting for it
+	measure   singleflight.Group
+	mu        sync.Mutex
+	checkedAt time.Time
+	lastLag   time.Duration
+	lastErr   error
+}
+
+func openReplica(ctx context.Context, dsn string) (*replica, error) {
+	pool, err := pgxpool.Connect(ctx, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("%w: replica: %s", kv.ErrConnectFailed, err)
+	}
+	return &replica{
+		pool:  pool,
+		lag:   func(ctx context.Context) (time.Duration, error) { return queryReplicationLag(ctx, pool) },
+		close: pool.Close,
+		now:   time.Now,
+	}, nil
+}
+
+// queryReplicationLag returns the time since the last transaction replayed on the replica, or zero when the replica
+// replayed all the WAL it received - the time since the last replay keeps growing while the primary is idle.
+func queryReplicationLag(ctx context.Context, q querier) (time.Duration, error) {
+	var seconds float64
+	err := q.QueryRow(ctx, `SELECT CASE WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+			ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0) END`).Scan(&seconds)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// withinLag reports if the replica is no more than maxLag behind the primary. A failure to measure the lag is
+// treated as too much lag. Readers needing a new measure wait for a single lag query, without holding the lock.
+// The lag query doesn't depend on the context of the reader that started it, and a measure that timed out isn't
+// reused by the following readers.
+func (r *replica) withinLag(ctx context.Context, maxLag time.Duration) bool {
+	r.mu.Lock()
+	fresh := !r.checkedAt.IsZero() && r.now().Sub(r.checkedAt) < replicaLagCheckInterval
+	lag, err := r.lastLag, r.lastErr
+	r.mu.Unlock()
+	if fresh {
+		return err == nil && lag <= maxLag
+	}
+	ch := r.measure.DoChan("lag", func() (interface{}, error) {
+		measureCtx, cancel := context.WithTimeout(context.Background(), replicaLagQueryTimeout)
+		defer cancel()
+		lag, err := r.lag(measureCtx)
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			return lag, err
+		}
+		r.mu.Lock()
+		r.lastLag, r.lastErr, r.checkedAt = lag, err, r.now()
+		r.mu.Unlock()
+		return lag, err
+	})
+	select {
+	case res := <-ch:
+		lag, _ = res.Val.(time.Duration)
+		return res.Err == nil && lag <= maxLag
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// reader returns where reads should be served from based on the context read preference
+func (s *Store) reader(ctx context.Context) querier {
+	pref, maxLag := kv.ReadPreferenceFromContext(ctx)
+	if pref != kv.ReadReplica || s.replica == nil {
+		return s.primary
+	}
+	if maxLag > 0 && !s.replica.withinLag(ctx, maxLag) {
+		return s.primary
+	}
+	return s.replica.pool
+}
@@ -0,0 +1,213 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/treeverse/lakefs/pkg/kv"
+)
+
+var errFakeQuery = errors.New("fake query")
+
+// fakeQuerier records the reads it serves, Get reads return its name as the value
+type fakeQuerier struct {
+	name  string
+	reads int
+}
+
+type fakeRow struct {
+	value []byte
+}
+
+func (r fakeRow) Scan(dest ...interface{}) error {
+	*(dest[0].(*[]byte)) = r.value
+	return nil
+}
+
+func (q *fakeQuerier) Query(_ context.Context, _ string, _ ...interface{}) (pgx.Rows, error) {
+	q.reads++
+	return nil, errFakeQuery
+}
+
+func (q *fakeQuerier) QueryRow(_ context.Context, _ string, _ ...interface{}) pgx.Row {
+	q.reads++
+	return fakeRow{value: []byte(q.name)}
+}
+
+func newReplicaTestStore(lag time.Duration, lagErr error) (*Store, *fakeQuerier, *fakeQuerier) {
+	primary := &fakeQuerier{name: "primary"}
+	replicaPool := &fakeQuerier{name: "replica"}
+	store := &Store{
+		Params:  parseStoreConfig(nil),
+		primary: primary,
+		replica: &replica{
+			pool: replicaPool,
+			lag:  func(context.Context) (time.Duration, error) { return lag, lagErr },
+			now:  time.Now,
+		},
+	}
+	return store, primary, replicaPool
+}
+
+func TestReadPreferenceRouting(t *testing.T) {
+	tests := []struct {
+		name     string
+		pref     kv.ReadPreference
+		maxLag   time.Duration
+		lag      time.Duration
+		lagErr   error
+		expected string
+	}{
+		{name: "default", pref: kv.ReadPrimary, expected: "primary"},
+		{name: "replica", pref: kv.ReadReplica, lag: time.Hour, expected: "replica"},
+		{name: "replica_within_lag", pref: kv.ReadReplica, maxLag: time.Second, lag: 100 * time.Millisecond, expected: "replica"},
+		{name: "replica_excessive_lag", pref: kv.ReadReplica, maxLag: time.Second, lag: time.Minute, expected: "primary"},
+		{name: "replica_lag_unknown", pref: kv.ReadReplica, maxLag: time.Second, lagErr: errFakeQuery, expected: "primary"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, primary, replicaPool := newReplicaTestStore(tt.lag, tt.lagErr)
+			ctx := kv.WithReadPreference(context.Background(), tt.pref, tt.maxLag)
+
+			value, err := store.Get(ctx, []byte("key"))
+			if err != nil {
+				t.Fatalf("Get: %s", err)
+			}
+			if string(value) != tt.expected {
+				t.Fatalf("Get served by %s, expected %s", value, tt.expected)
+			}
+
+			_, err = store.Scan(ctx, nil)
+			if !errors.Is(err, kv.ErrOperationFailed) {
+				t.Fatalf("Scan err=%v, expected fake query failure", err)
+			}
+			expectedPrimary, expectedReplica := 2, 0
+			if tt.expected == "replica" {
+				expectedPrimary, expectedReplica = 0, 2
+			}
+			if primary.reads != expectedPrimary || replicaPool.reads != expectedReplica {
+				t.Fatalf("reads primary=%d replica=%d, expected primary=%d replica=%d",
+					primary.reads, replicaPool.reads, expectedPrimary, expectedReplica)
+			}
+		})
+	}
+}
+
+func TestReadPreferenceNoReplica(t *testing.T) {
+	primary := &fakeQuerier{name: "primary"}
+	store := &Store{Params: parseStoreConfig(nil), primary: primary}
+	ctx := kv.WithReadPreference(context.Background(), kv.ReadReplica, 0)
+	value, err := store.Get(ctx, []byte("key"))
+	if err != nil {
+		t.Fatalf("Get: %s", err)
+	}
+	if string(value) != "primary" {
+		t.Fatalf("Get served by %s without a replica, expected primary", value)
+	}
+}
+
+func TestReplicaLagCached(t *testing.T) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	checks := 0
+	r := &replica{
+		lag: func(context.Context) (time.Duration, error) {
+			checks++
+			return time.Minute, nil
+		},
+		now: func() time.Time { return now },
+	}
+	ctx := context.Background()
+	if r.withinLag(ctx, time.Second) || r.withinLag(ctx, time.Second) {
+		t.Fatal("expected replica lag to exceed the bound")
+	}
+	if checks != 1 {
+		t.Fatalf("measured lag %d times, expected a single cached measure", checks)
+	}
+	now = now.Add(replicaLagCheckInterval)
+	if !r.withinLag(ctx, time.Hour) {
+		t.Fatal("expected replica lag within an hour bound")
+	}
+	if checks != 2 {
+		t.Fatalf("measured lag %d times, expected a new measure after the check interval", checks)
+	}
+}
+
+func TestReplicaLagSingleMeasure(t *testing.T) {
+	var checks int32
+	release := make(chan struct{})
+	r := &replica{
+		lag: func(context.Context) (time.Duration, error) {
+			atomic.AddInt32(&checks, 1)
+			<-release
+			return time.Millisecond, nil
+		},
+		now: time.Now,
+	}
+	const readers = 10
+	results := make(chan bool, readers)
+	for i := 0; i < readers; i++ {
+		go func() { results <- r.withinLag(context.Background(), time.Second) }()
+	}
+	// the lock is not held while measuring
+	time.Sleep(50 * time.Millisecond)
+	r.mu.Lock()
+	r.mu.Unlock()
+	close(release)
+	for i := 0; i < readers; i++ {
+		if !<-results {
+			t.Fatal("expected replica lag within the bound")
+		}
+	}
+	if n := atomic.LoadInt32(&checks); n != 1 {
+		t.Fatalf("measured lag %d times, expected concurrent readers to share a single measure", n)
+	}
+}
+
+func TestReplicaLagIgnoresReaderContext(t *testing.T) {
+	measured := make(chan error, 1)
+	r := &replica{
+		lag: func(ctx context.Context) (time.Duration, error) {
+			measured <- ctx.Err()
+			return time.Millisecond, ctx.Err()
+		},
+		now: time.Now,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if r.withinLag(ctx, time.Second) {
+		t.Fatal("expected a cancelled reader to fall back to the primary")
+	}
+	if err := <-measured; err != nil {
+		t.Fatalf("lag measured with context error %v, expected a context independent of the reader", err)
+	}
+	if !r.withinLag(context.Background(), time.Second) {
+		t.Fatal("expected replica lag within the bound after a cancelled reader")
+	}
+}
+
+func TestReplicaLagTimeoutNotCached(t *testing.T) {
+	checks := 0
+	lagErr := context.DeadlineExceeded
+	r := &replica{
+		lag: func(context.Context) (time.Duration, error) {
+			checks++
+			return 0, lagErr
+		},
+		now: time.Now,
+	}
+	ctx := context.Background()
+	if r.withinLag(ctx, time.Second) {
+		t.Fatal("expected a timed out measure to be treated as too much lag")
+	}
+	lagErr = nil
+	if !r.withinLag(ctx, time.Second) {
+		t.Fatal("expected a new measure after a timed out measure")
+	}
+	if checks != 2 {
+		t.Fatalf("measured lag %d times, expected a timed out measure not to be reused", checks)
+	}
+}
@@ -1,11 +1,15 @@
 package postgres
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"sort"
+	"sync"
 
 	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/treeverse/lakefs/pkg/kv"
@@ -17,6 +21,16 @@ type Store struct {
 	Pool           *pgxpool.Pool
 	Params         *Params
 	TableSanitized string
+	primary        querier
+	replica        *replica
+	refs           *poolRefs
+	closeOnce      sync.Once
+}
+
+// poolRefs counts the store handles sharing the same pool
+type poolRefs struct {
+	mu    sync.Mutex
+	count int
 }
 
 type EntriesIterator struct {
@@ -25,11 +39,26 @@ type EntriesIterator struct {
 	err   error
 }
 
+// snapshotEntriesIterator iterates the entries read by a snapshot transaction, ending the transaction when closed
+type snapshotEntriesIterator struct {
+	*EntriesIterator
+	ctx context.Context
+	tx  pgx.Tx
+}
+
+type KeysIterator struct {
+	rows pgx.Rows
+	key  []byte
+	err  error
+}
+
 const (
 	DriverName = "postgres"
 
 	defaultTableName = "kv"
 	paramTableName   = "lakefskv_table"
+	// paramReplica is the DSN of a read replica, used by reads requesting kv.ReadReplica
+	paramReplica = "lakefskv_replica"
 )
 
 //nolint:gochecknoinits
@@ -43,6 +72,9 @@ func (d *Driver) Open(ctx context.Context, name string) (kv.Store, error) {
 	if err != nil {
 		return nil, fmt.Errorf("%w: %s", kv.ErrDriverConfiguration, err)
 	}
+	// the replica DSN is not a server setting, don't pass it on the connection
+	replicaDSN := config.ConnConfig.RuntimeParams[paramReplica]
+	delete(config.ConnConfig.RuntimeParams, paramReplica)
 	pool, err := pgxpool.ConnectConfig(ctx, config)
 	if err != nil {
 		return nil, fmt.Errorf("%w: %s", kv.ErrConnectFailed, err)
@@ -70,10 +102,20 @@ func (d *Driver) Open(ctx context.Context, name string) (kv.Store, error) {
 	if err != nil {
 		return nil, fmt.Errorf("%w: %s", kv.ErrSetupFailed, err)
 	}
+	var rep *replica
+	if replicaDSN != "" {
+		rep, err = openReplica(ctx, replicaDSN)
+		if err != nil {
+			return nil, err
+		}
+	}
 	store := &Store{
 		Pool:           pool,
 		Params:         params,
 		TableSanitized: pgx.Identifier{params.TableName}.Sanitize(),
+		primary:        pool,
+		replica:        rep,
+		refs:           &poolRefs{count: 1},
 	}
 	pool = nil
 	return store, nil
@@ -107,7 +149,7 @@ func (s *Store) Get(ctx context.Context, key []byte) ([]byte, error) {
 	if key == nil {
 		return nil, kv.ErrMissingKey
 	}
-	row := s.Pool.QueryRow(ctx, `SELECT value FROM `+s.Params.SanitizedTableName+` WHERE key = $1`, key)
+	row := s.reader(ctx).QueryRow(ctx, `SELECT value FROM `+s.Params.SanitizedTableName+` WHERE key = $1`, key)
 	var val []byte
 	err := row.Scan(&val)
 	if errors.Is(err, pgx.ErrNoRows) {
@@ -161,6 +203,124 @@ func (s *Store) SetIf(ctx context.Context, key, value, valuePredicate []byte) er
 	return nil
 }
 
+func (s *Store) CompareAndSwapMany(ctx context.Context, ops []kv.CASOp) error {
+	if err := kv.ValidateCASOps(ops); err != nil {
+		return err
+	}
+	if len(ops) == 0 {
+		return nil
+	}
+	// lock the rows in key order, so concurrent calls on overlapping keys don't deadlock
+	sorted := make([]kv.CASOp, len(ops))
+	copy(sorted, ops)
+	sort.Slice(sorted, func(i, j int) bool { return bytes.Compare(sorted[i].Key, sorted[j].Key) < 0 })
+	tx, err := s.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", err, kv.ErrOperationFailed)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	for _, op := range sorted {
+		var res pgconn.CommandTag
+		if op.Predicate == nil {
+			res, err = tx.Exec(ctx, `INSERT INTO `+s.Params.SanitizedTableName+`(key,value) VALUES($1,$2) ON CONFLICT DO NOTHING`, op.Key, op.Value)
+		} else {
+			res, err = tx.Exec(ctx, `UPDATE `+s.Params.SanitizedTableName+` SET value=$2 WHERE key=$1 AND value=$3`, op.Key, op.Value, op.Predicate)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", err, kv.ErrOperationFailed)
+		}
+		if res.RowsAffected() != 1 {
+			return fmt.Errorf("%w: key %s", kv.ErrPredicateFailed, op.Key)
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", err, kv.ErrOperationFailed)
+	}
+	return nil
+}
+
+func (s *Store) SetIfFunc(ctx context.Context, key, value []byte, pred func(current []byte) bool) error {
+	if key == nil {
+		return kv.ErrMissingKey
+	}
+	if value == nil {
+		return kv.ErrMissingValue
+	}
+	tx, err := s.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", err, kv.ErrOperationFailed)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	// lock the current value until the transaction ends
+	var curr []byte
+	err = tx.QueryRow(ctx, `SELECT value FROM `+s.Params.SanitizedTableName+` WHERE key = $1 FOR UPDATE`, key).Scan(&curr)
+	exists := true
+	if errors.Is(err, pgx.ErrNoRows) {
+		exists = false
+	} else if err != nil {
+		return fmt.Errorf("%s: %w", err, kv.ErrOperationFailed)
+	}
+	if !pred(curr) {
+		return kv.ErrPredicateFailed
+	}
+	var res pgconn.CommandTag
+	if exists {
+		res, err = tx.Exec(ctx, `UPDATE `+s.Params.SanitizedTableName+` SET value=$2 WHERE key=$1`, key, value)
+	} else {
+		// a missing key can't be locked, fail in case it was created after it was read
+		res, err = tx.Exec(ctx, `INSERT INTO `+s.Params.SanitizedTableName+`(key,value) VALUES($1,$2) ON CONFLICT DO NOTHING`, key, value)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", err, kv.ErrOperationFailed)
+	}
+	if res.RowsAffected() != 1 {
+		return kv.ErrPredicateFailed
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", err, kv.ErrOperationFailed)
+	}
+	return nil
+}
+
+func (s *Store) GetOrSet(ctx context.Context, key, defaultValue []byte) ([]byte, bool, error) {
+	if key == nil {
+		return nil, false, kv.ErrMissingKey
+	}
+	if defaultValue == nil {
+		return nil, false, kv.ErrMissingValue
+	}
+	tx, err := s.Pool.Begin(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("%s: %w", err, kv.ErrOperationFailed)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	// insert returns no row when the key exists, read it instead of updating it - an update writes a new row
+	// version on every read of an existing key. Each statement sees the rows committed before it started, so the
+	// key is inserted again if it was deleted in between.
+	var value []byte
+	for {
+		err = tx.QueryRow(ctx, `INSERT INTO `+s.Params.SanitizedTableName+`(key,value) VALUES($1,$2)
+			ON CONFLICT (key) DO NOTHING RETURNING value`, key, defaultValue).Scan(&value)
+		if err == nil {
+			if err := tx.Commit(ctx); err != nil {
+				return nil, false, fmt.Errorf("%s: %w", err, kv.ErrOperationFailed)
+			}
+			return value, true, nil
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return nil, false, fmt.Errorf("%s: %w", err, kv.ErrOperationFailed)
+		}
+		err = tx.QueryRow(ctx, `SELECT value FROM `+s.Params.SanitizedTableName+` WHERE key = $1`, key).Scan(&value)
+		if err == nil {
+			return value, false, nil
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return nil, false, fmt.Errorf("%s: %w", err, kv.ErrOperationFailed)
+		}
+	}
+}
+
 func (s *Store) Delete(ctx context.Context, key []byte) error {
 	if key == nil {
 		return kv.ErrMissingKey
@@ -172,15 +332,184 @@ func (s *Store) Delete(ctx context.Context, key []byte) error {
 	return nil
 }
 
+func (s *Store) DeleteIf(ctx context.Context, key, valuePredicate []byte) error {
+	if key == nil {
+		return kv.ErrMissingKey
+	}
+	if valuePredicate == nil {
+		return kv.ErrMissingValue
+	}
+	res, err := s.Pool.Exec(ctx, `DELETE FROM `+s.Params.SanitizedTableName+` WHERE key=$1 AND value=$2`, key, valuePredicate)
+	if err != nil {
+		return fmt.Errorf("%s: %w", err, kv.ErrOperationFailed)
+	}
+	if res.RowsAffected() != 1 {
+		return kv.ErrPredicateFailed
+	}
+	return nil
+}
+
+func (s *Store) DeleteBatch(ctx context.Context, keys [][]byte) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	for _, key := range keys {
+		if key == nil {
+			return kv.ErrMissingKey
+		}
+	}
+	_, err := s.Pool.Exec(ctx, `DELETE FROM `+s.Params.SanitizedTableName+` WHERE key = ANY($1)`, keys)
+	if err != nil {
+		return fmt.Errorf("%s: %w", err, kv.ErrOperationFailed)
+	}
+	return nil
+}
+
+func (s *Store) DeletePrefix(ctx context.Context, prefix []byte, deleteAll bool) (int64, error) {
+	if len(prefix) == 0 && !deleteAll {
+		return 0, kv.ErrMissingKey
+	}
+	var (
+		res pgconn.CommandTag
+		err error
+	)
+	upper := prefixUpperBound(prefix)
+	switch {
+	case len(prefix) == 0:
+		res, err = s.Pool.Exec(ctx, `DELETE FROM `+s.Params.SanitizedTableName)
+	case upper == nil:
+		res, err = s.Pool.Exec(ctx, `DELETE FROM `+s.Params.SanitizedTableName+` WHERE key >= $1`, prefix)
+	default:
+		res, err = s.Pool.Exec(ctx, `DELETE FROM `+s.Params.SanitizedTableName+` WHERE key >= $1 AND key < $2`, prefix, upper)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", err, kv.ErrOperationFailed)
+	}
+	return res.RowsAffected(), nil
+}
+
+func (s *Store) Rename(ctx context.Context, oldKey, newKey []byte, overwrite bool) error {
+	if oldKey == nil || newKey == nil {
+		return kv.ErrMissingKey
+	}
+	tx, err := s.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", err, kv.ErrOperationFailed)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	var value []byte
+	err = tx.QueryRow(ctx, `SELECT value FROM `+s.Params.SanitizedTableName+` WHERE key = $1 FOR UPDATE`, oldKey).Scan(&value)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return kv.ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", err, kv.ErrOperationFailed)
+	}
+	if bytes.Equal(oldKey, newKey) {
+		return nil
+	}
+	var res pgconn.CommandTag
+	if overwrite {
+		res, err = tx.Exec(ctx, `INSERT INTO `+s.Params.SanitizedTableName+`(key,value) VALUES($1,$2)
+			ON CONFLICT (key) DO UPDATE SET value = $2`, newKey, value)
+	} else {
+		res, err = tx.Exec(ctx, `INSERT INTO `+s.Params.SanitizedTableName+`(key,value) VALUES($1,$2) ON CONFLICT DO NOTHING`, newKey, value)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", err, kv.ErrOperationFailed)
+	}
+	if res.RowsAffected() != 1 {
+		return fmt.Errorf("%w: key %s exists", kv.ErrPredicateFailed, newKey)
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM `+s.Params.SanitizedTableName+` WHERE key = $1`, oldKey); err != nil {
+		return fmt.Errorf("%s: %w", err, kv.ErrOperationFailed)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", err, kv.ErrOperationFailed)
+	}
+	return nil
+}
+
+func (s *Store) RenamePrefix(ctx context.Context, oldPrefix, newPrefix []byte, overwrite bool) (int64, error) {
+	if err := kv.ValidateRenamePrefix(oldPrefix, newPrefix); err != nil {
+		return 0, err
+	}
+	// keys of the old prefix, $1 is the old prefix and $2 its upper bound
+	inPrefix := `key >= $1 AND key < $2`
+	args := []interface{}{oldPrefix}
+	if upper := prefixUpperBound(oldPrefix); upper != nil {
+		args = append(args, upper)
+	} else {
+		inPrefix = `key >= $1`
+	}
+	// the renamed key, $n is the new prefix and $n+1 the position following the old prefix
+	renamed := fmt.Sprintf(`$%d::bytea || substring(key from $%d::int)`, len(args)+1, len(args)+2)
+	args = append(args, newPrefix, len(oldPrefix)+1)
+
+	tx, err := s.Pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", err, kv.ErrOperationFailed)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	// prefixes don't overlap, so the renamed keys are never in the old prefix
+	renamedKeys := `SELECT ` + renamed + ` FROM ` + s.Params.SanitizedTableName + ` WHERE ` + inPrefix
+	if overwrite {
+		_, err = tx.Exec(ctx, `DELETE FROM `+s.Params.SanitizedTableName+` WHERE key IN (`+renamedKeys+`)`, args...)
+	} else {
+		var exists bool
+		err = tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM `+s.Params.SanitizedTableName+` WHERE key IN (`+renamedKeys+`))`, args...).Scan(&exists)
+		if err == nil && exists {
+			return 0, fmt.Errorf("%w: keys renamed to %s exist", kv.ErrPredicateFailed, newPrefix)
+		}
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", err, kv.ErrOperationFailed)
+	}
+	res, err := tx.Exec(ctx, `UPDATE `+s.Params.SanitizedTableName+` SET key = `+renamed+` WHERE `+inPrefix, args...)
+	if isUniqueViolation(err) {
+		// a key under newPrefix was set after the existence check
+		return 0, fmt.Errorf("%w: keys renamed to %s exist", kv.ErrPredicateFailed, newPrefix)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", err, kv.ErrOperationFailed)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("%s: %w", err, kv.ErrOperationFailed)
+	}
+	return res.RowsAffected(), nil
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation
+}
+
+// prefixUpperBound returns the smallest key greater than all the keys starting with prefix.
+// Returns nil in case there is no such key (empty prefix or all bytes are 0xff).
+func prefixUpperBound(prefix []byte) []byte {
+	upper := make([]byte, len(prefix))
+	copy(upper, prefix)
+	for i := len(upper) - 1; i >= 0; i-- {
+		if upper[i] < 0xff {
+			upper[i]++
+			return upper[:i+1]
+		}
+	}
+	return nil
+}
+
 func (s *Store) Scan(ctx context.Context, start []byte) (kv.EntriesIterator, error) {
 	var (
 		rows pgx.Rows
 		err  error
 	)
+	reader := s.reader(ctx)
 	if start == nil {
-		rows, err = s.Pool.Query(ctx, `SELECT key,value FROM `+s.Params.SanitizedTableName+` ORDER BY key`)
+		rows, err = reader.Query(ctx, `SELECT key,value FROM `+s.Params.SanitizedTableName+` ORDER BY key`)
 	} else {
-		rows, err = s.Pool.Query(ctx, `SELECT key,value FROM `+s.Params.SanitizedTableName+` WHERE key >= $1 ORDER BY key`, start)
+		rows, err = reader.Query(ctx, `SELECT key,value FROM `+s.Params.SanitizedTableName+` WHERE key >= $1 ORDER BY key`, start)
 	}
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", err, kv.ErrOperationFailed)
@@ -190,8 +519,151 @@ func (s *Store) Scan(ctx context.Context, start []byte) (kv.EntriesIterator, err
 	}, nil
 }
 
+// ScanSnapshot scans using a read only repeatable read transaction, the transaction and its pool connection are
+// held until the iterator is closed. Long iterations delay vacuuming rows deleted or updated since the snapshot.
+func (s *Store) ScanSnapshot(ctx context.Context, start []byte) (kv.EntriesIterator, error) {
+	tx, err := s.Pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", err, kv.ErrOperationFailed)
+	}
+	var rows pgx.Rows
+	if start == nil {
+		rows, err = tx.Query(ctx, `SELECT key,value FROM `+s.Params.SanitizedTableName+` ORDER BY key`)
+	} else {
+		rows, err = tx.Query(ctx, `SELECT key,value FROM `+s.Params.SanitizedTableName+` WHERE key >= $1 ORDER BY key`, start)
+	}
+	if err != nil {
+		_ = tx.Rollback(ctx)
+		return nil, fmt.Errorf("%s: %w", err, kv.ErrOperationFailed)
+	}
+	return &snapshotEntriesIterator{
+		EntriesIterator: &EntriesIterator{rows: rows},
+		ctx:             ctx,
+		tx:              tx,
+	}, nil
+}
+
+func (s *Store) GetRange(ctx context.Context, prefix []byte, limit int) (map[string][]byte, error) {
+	limit = kv.GetRangeLimit(limit)
+	var (
+		rows pgx.Rows
+		err  error
+	)
+	reader := s.reader(ctx)
+	upper := prefixUpperBound(prefix)
+	switch {
+	case len(prefix) == 0:
+		rows, err = reader.Query(ctx, `SELECT key,value FROM `+s.Params.SanitizedTableName+` ORDER BY key LIMIT $1`, limit)
+	case upper == nil:
+		rows, err = reader.Query(ctx, `SELECT key,value FROM `+s.Params.SanitizedTableName+` WHERE key >= $1 ORDER BY key LIMIT $2`, prefix, limit)
+	default:
+		rows, err = reader.Query(ctx, `SELECT key,value FROM `+s.Params.SanitizedTableName+` WHERE key >= $1 AND key < $2 ORDER BY key LIMIT $3`, prefix, upper, limit)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", err, kv.ErrOperationFailed)
+	}
+	defer rows.Close()
+	res := make(map[string][]byte)
+	for rows.Next() {
+		var key, value []byte
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, fmt.Errorf("%s: %w", err, kv.ErrOperationFailed)
+		}
+		res[string(key)] = value
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", err, kv.ErrOperationFailed)
+	}
+	return res, nil
+}
+
+func (s *Store) ListKeys(ctx context.Context, prefix []byte) (kv.KeysIterator, error) {
+	var (
+		rows pgx.Rows
+		err  error
+	)
+	upper := prefixUpperBound(prefix)
+	switch {
+	case len(prefix) == 0:
+		rows, err = s.Pool.Query(ctx, `SELECT key FROM `+s.Params.SanitizedTableName+` ORDER BY key`)
+	case upper == nil:
+		rows, err = s.Pool.Query(ctx, `SELECT key FROM `+s.Params.SanitizedTableName+` WHERE key >= $1 ORDER BY key`, prefix)
+	default:
+		rows, err = s.Pool.Query(ctx, `SELECT key FROM `+s.Params.SanitizedTableName+` WHERE key >= $1 AND key < $2 ORDER BY key`, prefix, upper)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", err, kv.ErrOperationFailed)
+	}
+	return &KeysIterator{
+		rows: rows,
+	}, nil
+}
+
+// Flush returns immediately, each write is committed before it returns
+func (s *Store) Flush(_ context.Context) error {
+	return nil
+}
+
+// Clone returns a new store handle sharing the same pool. The pool is closed after all handles are closed, Clone
+// panics once it is closed as the clone would use a closed pool.
+func (s *Store) Clone() kv.Store {
+	s.refs.mu.Lock()
+	if s.refs.count == 0 {
+		s.refs.mu.Unlock()
+		panic("kv postgres: clone of a store after all its handles were closed")
+	}
+	s.refs.count++
+	s.refs.mu.Unlock()
+	return &Store{
+		Pool:           s.Pool,
+		Params:         s.Params,
+		TableSanitized: s.TableSanitized,
+		primary:        s.primary,
+		replica:        s.replica,
+		refs:           s.refs,
+	}
+}
+
+// PoolStats returns a snapshot of the store connection pool stats
+func (s *Store) PoolStats() (kv.PoolStatsSnapshot, error) {
+	stat := s.Pool.Stat()
+	return kv.PoolStatsSnapshot{
+		AcquireCount:         stat.AcquireCount(),
+		AcquireDuration:      stat.AcquireDuration(),
+		CanceledAcquireCount: stat.CanceledAcquireCount(),
+		EmptyAcquireCount:    stat.EmptyAcquireCount(),
+		AcquiredConns:        stat.AcquiredConns(),
+		ConstructingConns:    stat.ConstructingConns(),
+		IdleConns:            stat.IdleConns(),
+		MaxConns:             stat.MaxConns(),
+		TotalConns:           stat.TotalConns(),
+	}, nil
+}
+
+// Compact vacuums the store table, reclaiming the space of deleted entries and updating the table statistics
+func (s *Store) Compact(ctx context.Context) error {
+	_, err := s.Pool.Exec(ctx, `VACUUM ANALYZE `+s.Params.SanitizedTableName)
+	if err != nil {
+		return fmt.Errorf("%s: %w", err, kv.ErrOperationFailed)
+	}
+	return nil
+}
+
+// Close releases the store handle, closing the pool when called on the last handle. Calling Close more than once
+// on the same handle has no effect.
 func (s *Store) Close() {
-	s.Pool.Close()
+	s.closeOnce.Do(func() {
+		s.refs.mu.Lock()
+		s.refs.count--
+		last := s.refs.count == 0
+		s.refs.mu.Unlock()
+		if last {
+			s.Pool.Close()
+			if s.replica != nil {
+				s.replica.close()
+			}
+		}
+	})
 }
 
 // Next reads the next key/value.
@@ -233,3 +705,48 @@ func (e *EntriesIterator) Close() {
 	e.entry = nil
 	e.err = kv.ErrClosedEntries
 }
+
+func (e *snapshotEntriesIterator) Close() {
+	e.EntriesIterator.Close()
+	_ = e.tx.Rollback(e.ctx)
+}
+
+// Next reads the next key.
+func (k *KeysIterator) Next() bool {
+	if k.err != nil {
+		return false
+	}
+	k.key = nil
+	if !k.rows.Next() {
+		return false
+	}
+	var key []byte
+	if err := k.rows.Scan(&key); err != nil {
+		k.err = fmt.Errorf("%s: %w", err, kv.ErrOperationFailed)
+		return false
+	}
+	k.key = key
+	return true
+}
+
+func (k *KeysIterator) Key() []byte {
+	return k.key
+}
+
+// Err return the last scan error or the cursor error
+func (k *KeysIterator) Err() error {
+	if k.err != nil {
+		return k.err
+	}
+	if err := k.rows.Err(); err != nil {
+		k.err = fmt.Errorf("%s: %w", err, kv.ErrOperationFailed)
+		return k.err
+	}
+	return nil
+}
+
+func (k *KeysIterator) Close() {
+	k.rows.Close()
+	k.key = nil
+	k.err = kv.ErrClosedEntries
+}
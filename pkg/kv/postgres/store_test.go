@@ -1,12 +1,177 @@
 package postgres_test
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
 	"testing"
 
+	"github.com/treeverse/lakefs/pkg/kv"
 	"github.com/treeverse/lakefs/pkg/kv/kvtest"
-	_ "github.com/treeverse/lakefs/pkg/kv/postgres"
+	"github.com/treeverse/lakefs/pkg/kv/postgres"
 )
 
 func TestPostgresKV(t *testing.T) {
 	kvtest.TestDriver(t, "postgres", databaseURI)
 }
+
+func TestPostgresClonePool(t *testing.T) {
+	ctx := context.Background()
+	store, err := kv.Open(ctx, postgres.DriverName, databaseURI)
+	if err != nil {
+		t.Fatalf("failed to open store: %s", err)
+	}
+	clone := store.Clone()
+	pool := store.(*postgres.Store).Pool
+
+	store.Close()
+	// closing the same handle twice should not release the pool used by the clone
+	store.Close()
+	if _, err := clone.Get(ctx, []byte("clone-pool-key")); !errors.Is(err, kv.ErrNotFound) {
+		t.Fatalf("Get using clone after store close err=%v, expected %s", err, kv.ErrNotFound)
+	}
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		t.Fatalf("expected pool to be open while clone is open: %s", err)
+	}
+	conn.Release()
+
+	clone.Close()
+	if conn, err := pool.Acquire(ctx); err == nil {
+		conn.Release()
+		t.Fatal("expected pool to be closed after the last handle closed")
+	}
+}
+
+func TestPostgresCloneClosed(t *testing.T) {
+	ctx := context.Background()
+	store, err := kv.Open(ctx, postgres.DriverName, databaseURI)
+	if err != nil {
+		t.Fatalf("failed to open store: %s", err)
+	}
+	store.Close()
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatal("expected Clone after the last handle closed to panic")
+		}
+	}()
+	_ = store.Clone()
+}
+
+func TestPostgresDeletePrefixAll(t *testing.T) {
+	ctx := context.Background()
+	// use a dedicated table, deleting all keys should not affect other tests
+	store, err := kv.Open(ctx, postgres.DriverName, databaseURI+"&lakefskv_table=kv_delete_all")
+	if err != nil {
+		t.Fatalf("failed to open store: %s", err)
+	}
+	defer store.Close()
+
+	keys := []string{"a", "b/1", "b/2", "c"}
+	for _, k := range keys {
+		if err := store.Set(ctx, []byte(k), []byte("value")); err != nil {
+			t.Fatalf("failed to set key '%s': %s", k, err)
+		}
+	}
+	deleted, err := store.DeletePrefix(ctx, nil, true)
+	if err != nil {
+		t.Fatalf("DeletePrefix all: %s", err)
+	}
+	if deleted != int64(len(keys)) {
+		t.Fatalf("DeletePrefix all deleted %d keys, expected %d", deleted, len(keys))
+	}
+}
+
+func TestPostgresPoolStats(t *testing.T) {
+	const iterators = 3
+	ctx := context.Background()
+	store, err := kv.Open(ctx, postgres.DriverName, databaseURI)
+	if err != nil {
+		t.Fatalf("failed to open store: %s", err)
+	}
+	defer store.Close()
+
+	before, err := kv.GetPoolStats(store)
+	if err != nil {
+		t.Fatalf("GetPoolStats: %s", err)
+	}
+	// open scans hold their connection until closed
+	var wg sync.WaitGroup
+	its := make([]kv.EntriesIterator, iterators)
+	errs := make([]error, iterators)
+	for i := 0; i < iterators; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			its[i], errs[i] = store.Scan(ctx, nil)
+		}(i)
+	}
+	wg.Wait()
+	for i := 0; i < iterators; i++ {
+		if errs[i] != nil {
+			t.Fatalf("Scan: %s", errs[i])
+		}
+		defer its[i].Close()
+	}
+
+	stats, err := kv.GetPoolStats(store)
+	if err != nil {
+		t.Fatalf("GetPoolStats: %s", err)
+	}
+	if stats.AcquiredConns < iterators {
+		t.Fatalf("pool stats acquired connections %d, expected at least %d", stats.AcquiredConns, iterators)
+	}
+	if stats.AcquireCount < before.AcquireCount+iterators {
+		t.Fatalf("pool stats acquire count %d, expected at least %d", stats.AcquireCount, before.AcquireCount+iterators)
+	}
+	if stats.TotalConns < stats.AcquiredConns || stats.MaxConns < stats.TotalConns {
+		t.Fatalf("pool stats %+v, expected acquired <= total <= max connections", stats)
+	}
+}
+
+func TestPostgresCompact(t *testing.T) {
+	ctx := context.Background()
+	store, err := kv.Open(ctx, postgres.DriverName, databaseURI+"&lakefskv_table=kv_compact")
+	if err != nil {
+		t.Fatalf("failed to open store: %s", err)
+	}
+	defer store.Close()
+
+	const items = 100
+	for i := 0; i < items; i++ {
+		k := fmt.Sprintf("compact/%04d", i)
+		if err := store.Set(ctx, []byte(k), []byte("value")); err != nil {
+			t.Fatalf("failed to set key '%s': %s", k, err)
+		}
+	}
+	if _, err := store.DeletePrefix(ctx, []byte("compact/00"), false); err != nil {
+		t.Fatalf("DeletePrefix: %s", err)
+	}
+	if err := kv.Compact(ctx, store); err != nil {
+		t.Fatalf("Compact: %s", err)
+	}
+	iter, err := kv.ScanPrefix(ctx, store, []byte("compact/"))
+	if err != nil {
+		t.Fatalf("ScanPrefix after Compact: %s", err)
+	}
+	defer iter.Close()
+	scanned := 0
+	for iter.Next() {
+		scanned++
+	}
+	if err := iter.Err(); err != nil {
+		t.Fatalf("ScanPrefix after Compact ended with an error: %s", err)
+	}
+	if expected := items - 10; scanned != expected {
+		t.Fatalf("ScanPrefix after Compact found %d entries, expected %d", scanned, expected)
+	}
+}
+
+func BenchmarkPostgresDeleteBatch(b *testing.B) {
+	kvtest.BenchmarkDeleteBatch(b, postgres.DriverName, databaseURI)
+}
+
+func BenchmarkPostgresListKeys(b *testing.B) {
+	kvtest.BenchmarkListKeys(b, postgres.DriverName, databaseURI)
+}
@@ -0,0 +1,39 @@
+package kv
+
+import (
+	"context"
+	"time"
+)
+
+// ReadPreference selects where a driver serves Get, Scan and GetRange from, when it has replicas configured.
+// Writes are always served by the primary.
+type ReadPreference int
+
+const (
+	// ReadPrimary reads from the primary, the default
+	ReadPrimary ReadPreference = iota
+	// ReadReplica reads from a replica when one is configured, accepting stale reads
+	ReadReplica
+)
+
+type readPreferenceKey struct{}
+
+type readPreferenceValue struct {
+	pref   ReadPreference
+	maxLag time.Duration
+}
+
+// WithReadPreference returns a context requesting reads using pref. Using ReadReplica with a positive maxLag
+// bounds the staleness: drivers fall back to the primary when the replica lags behind more than maxLag.
+func WithReadPreference(ctx context.Context, pref ReadPreference, maxLag time.Duration) context.Context {
+	return context.WithValue(ctx, readPreferenceKey{}, readPreferenceValue{pref: pref, maxLag: maxLag})
+}
+
+// ReadPreferenceFromContext returns the read preference and max lag set on ctx, ReadPrimary if none was set
+func ReadPreferenceFromContext(ctx context.Context) (ReadPreference, time.Duration) {
+	v, ok := ctx.Value(readPreferenceKey{}).(readPreferenceValue)
+	if !ok {
+		return ReadPrimary, 0
+	}
+	return v.pref, v.maxLag
+}
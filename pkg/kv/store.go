@@ -1,6 +1,7 @@
 package kv
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -8,21 +9,36 @@ import (
 	"sync"
 )
 
-const PathDelimiter = "/"
+const (
+	PathDelimiter = "/"
+
+	// MaxGetRangeLimit is the most entries GetRange returns, used also when GetRange is called without a limit
+	MaxGetRangeLimit = 10000
+)
 
 var (
 	ErrClosedEntries       = errors.New("closed entries")
 	ErrConnectFailed       = errors.New("connect failed")
 	ErrDriverConfiguration = errors.New("driver configuration")
+	ErrDuplicateKey        = errors.New("duplicate key")
 	ErrMissingKey          = errors.New("missing key")
 	ErrMissingValue        = errors.New("missing value")
 	ErrNotFound            = errors.New("not found")
 	ErrOperationFailed     = errors.New("operation failed")
+	ErrOverlappingPrefix   = errors.New("overlapping prefix")
 	ErrPredicateFailed     = errors.New("predicate failed")
 	ErrSetupFailed         = errors.New("setup failed")
 	ErrUnknownDriver       = errors.New("unknown driver")
 )
 
+// GetRangeLimit returns the number of entries GetRange should return for the requested limit
+func GetRangeLimit(limit int) int {
+	if limit <= 0 || limit > MaxGetRangeLimit {
+		return MaxGetRangeLimit
+	}
+	return limit
+}
+
 func FormatPath(p ...string) string {
 	return strings.Join(p, PathDelimiter)
 }
@@ -49,16 +65,115 @@ type Store interface {
 	//  this is intentionally simplistic: we can model a better abstraction on top, keeping this interface simple for implementors
 	SetIf(ctx context.Context, key, value, valuePredicate []byte) error
 
+	// CompareAndSwapMany atomically applies all the ops, each one setting its key value as SetIf does using its
+	//  Predicate. Either all ops are applied or none are, in which case it returns an ErrPredicateFailed error
+	//  naming a key with a failing predicate. A key can appear in a single op, otherwise ErrDuplicateKey is returned.
+	CompareAndSwapMany(ctx context.Context, ops []CASOp) error
+
+	// SetIfFunc stores the given value only if pred accepts the current value, otherwise returns ErrPredicateFailed.
+	//  pred is called with nil current value when the key doesn't exist. Reading the current value and setting the
+	//  new one is done atomically.
+	SetIfFunc(ctx context.Context, key, value []byte, pred func(current []byte) bool) error
+
+	// GetOrSet atomically returns the current value of key, or sets it to defaultValue when the key doesn't exist.
+	//  created reports if defaultValue was set, only a single caller creating the key concurrently gets created set.
+	GetOrSet(ctx context.Context, key, defaultValue []byte) (value []byte, created bool, err error)
+
 	// Delete will delete the key, no error in if key doesn't exist
 	Delete(ctx context.Context, key []byte) error
 
+	// DeleteIf deletes the key only if its current value is valuePredicate, returns an ErrPredicateFailed error
+	//  if the value doesn't match or the key doesn't exist.
+	DeleteIf(ctx context.Context, key, valuePredicate []byte) error
+
+	// DeleteBatch deletes all the given keys, keys that don't exist are ignored
+	DeleteBatch(ctx context.Context, keys [][]byte) error
+
+	// DeletePrefix deletes all keys starting with prefix and returns the number of keys deleted.
+	//  An empty prefix fails with ErrMissingKey, unless deleteAll is set to explicitly delete all keys.
+	DeletePrefix(ctx context.Context, prefix []byte, deleteAll bool) (int64, error)
+
+	// Rename atomically moves the value of oldKey to newKey. Fails with ErrNotFound when oldKey doesn't exist, and
+	//  with ErrPredicateFailed when newKey exists, unless overwrite is set.
+	Rename(ctx context.Context, oldKey, newKey []byte, overwrite bool) error
+
+	// RenamePrefix atomically moves all keys starting with oldPrefix to start with newPrefix instead, and returns
+	//  the number of keys moved. Fails with ErrPredicateFailed when any of the new keys exists, unless overwrite
+	//  is set. The prefixes must not overlap, see ValidateRenamePrefix.
+	RenamePrefix(ctx context.Context, oldPrefix, newPrefix []byte, overwrite bool) (int64, error)
+
 	// Scan returns entries that can be read by key order, starting at or after the `start` position
 	Scan(ctx context.Context, start []byte) (EntriesIterator, error)
 
+	// ScanSnapshot returns entries as Scan does, read from a snapshot of the store taken when it is called: writes
+	//  made while iterating are not returned. The snapshot is held until the iterator is closed, which may keep
+	//  database resources (i.e. a transaction and its connection) for as long as the iteration takes.
+	ScanSnapshot(ctx context.Context, start []byte) (EntriesIterator, error)
+
+	// GetRange returns up to limit entries with keys starting with prefix, mapped by key. A zero limit, or a limit
+	//  above MaxGetRangeLimit, returns up to MaxGetRangeLimit entries, taken by key order.
+	GetRange(ctx context.Context, prefix []byte, limit int) (map[string][]byte, error)
+
+	// ListKeys returns the keys starting with prefix by key order, without reading their values
+	ListKeys(ctx context.Context, prefix []byte) (KeysIterator, error)
+
+	// Flush forces writes buffered by the store to the database, so they are visible to any other handle.
+	//  Stores that don't buffer writes return immediately.
+	Flush(ctx context.Context) error
+
+	// Clone returns an independent handle to the same database store. Each handle must be closed separately,
+	//  resources shared between handles are released only after the last handle is closed.
+	Clone() Store
+
 	// Close access to the database store. After calling Close the instance is unusable.
 	Close()
 }
 
+// CASOp is a single compare-and-swap of CompareAndSwapMany
+type CASOp struct {
+	Key   []byte
+	Value []byte
+	// Predicate is the expected current value, or nil when the key should not exist
+	Predicate []byte
+}
+
+// ValidateCASOps checks all ops have a key and a value, and that no key appears in more than one op
+func ValidateCASOps(ops []CASOp) error {
+	keys := make(map[string]struct{}, len(ops))
+	for _, op := range ops {
+		if op.Key == nil {
+			return ErrMissingKey
+		}
+		if op.Value == nil {
+			return ErrMissingValue
+		}
+		if _, ok := keys[string(op.Key)]; ok {
+			return fmt.Errorf("%w: %s", ErrDuplicateKey, op.Key)
+		}
+		keys[string(op.Key)] = struct{}{}
+	}
+	return nil
+}
+
+// ValidateRenamePrefix checks oldPrefix is set and that neither prefix starts with the other, as keys moved to
+// newPrefix would otherwise also match oldPrefix
+func ValidateRenamePrefix(oldPrefix, newPrefix []byte) error {
+	if len(oldPrefix) == 0 {
+		return ErrMissingKey
+	}
+	if bytes.HasPrefix(oldPrefix, newPrefix) || bytes.HasPrefix(newPrefix, oldPrefix) {
+		return fmt.Errorf("%w: %s and %s", ErrOverlappingPrefix, oldPrefix, newPrefix)
+	}
+	return nil
+}
+
+// RenamedKey returns key, that starts with oldPrefix, starting with newPrefix instead
+func RenamedKey(key, oldPrefix, newPrefix []byte) []byte {
+	renamed := make([]byte, 0, len(newPrefix)+len(key)-len(oldPrefix))
+	renamed = append(renamed, newPrefix...)
+	return append(renamed, key[len(oldPrefix):]...)
+}
+
 // EntriesIterator used to enumerate over Scan results
 type EntriesIterator interface {
 	// Next should be called first before access Entry.
@@ -76,6 +191,22 @@ type EntriesIterator interface {
 	Close()
 }
 
+// KeysIterator used to enumerate over ListKeys results
+type KeysIterator interface {
+	// Next should be called first before access Key.
+	// it will process the next key and return true if it was successful, and false when none or error.
+	Next() bool
+
+	// Key current key read after calling Next, set to nil in case of an error or no more keys.
+	Key() []byte
+
+	// Err set to last error by reading the next key.
+	Err() error
+
+	// Close should be called at the end of processing keys, required to release resources used to list keys.
+	Close()
+}
+
 // Entry holds a pair of key/value
 type Entry struct {
 	Key   []byte
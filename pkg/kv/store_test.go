@@ -35,14 +35,66 @@ func (m *MockStore) SetIf(_ context.Context, _, _, _ []byte) error {
 	return errNotImplemented
 }
 
+func (m *MockStore) CompareAndSwapMany(_ context.Context, _ []kv.CASOp) error {
+	return errNotImplemented
+}
+
+func (m *MockStore) SetIfFunc(_ context.Context, _, _ []byte, _ func([]byte) bool) error {
+	return errNotImplemented
+}
+
+func (m *MockStore) GetOrSet(_ context.Context, _, _ []byte) ([]byte, bool, error) {
+	return nil, false, errNotImplemented
+}
+
+func (m *MockStore) Rename(_ context.Context, _, _ []byte, _ bool) error {
+	return errNotImplemented
+}
+
+func (m *MockStore) RenamePrefix(_ context.Context, _, _ []byte, _ bool) (int64, error) {
+	return 0, errNotImplemented
+}
+
+func (m *MockStore) ScanSnapshot(_ context.Context, _ []byte) (kv.EntriesIterator, error) {
+	return nil, errNotImplemented
+}
+
 func (m *MockStore) Delete(_ context.Context, _ []byte) error {
 	return errNotImplemented
 }
 
+func (m *MockStore) DeleteIf(_ context.Context, _, _ []byte) error {
+	return errNotImplemented
+}
+
+func (m *MockStore) DeleteBatch(_ context.Context, _ [][]byte) error {
+	return errNotImplemented
+}
+
+func (m *MockStore) DeletePrefix(_ context.Context, _ []byte, _ bool) (int64, error) {
+	return 0, errNotImplemented
+}
+
 func (m *MockStore) Scan(_ context.Context, _ []byte) (kv.EntriesIterator, error) {
 	return nil, errNotImplemented
 }
 
+func (m *MockStore) ListKeys(_ context.Context, _ []byte) (kv.KeysIterator, error) {
+	return nil, errNotImplemented
+}
+
+func (m *MockStore) GetRange(_ context.Context, _ []byte, _ int) (map[string][]byte, error) {
+	return nil, errNotImplemented
+}
+
+func (m *MockStore) Flush(_ context.Context) error {
+	return nil
+}
+
+func (m *MockStore) Clone() kv.Store {
+	return m
+}
+
 func (m *MockStore) Close() {}
 
 func (m *MockDriver) Open(_ context.Context, dsn string) (kv.Store, error) {
@@ -0,0 +1,213 @@
+package kv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// TimeoutStore wraps a Store, bounding each operation by Timeout. A shorter deadline set by the caller context is
+// kept. Iterators returned by Scan, ScanSnapshot and ListKeys are bounded from the call until they are closed.
+type TimeoutStore struct {
+	Store   Store
+	Timeout time.Duration
+}
+
+// timeoutEntriesIterator releases the operation context when closed
+type timeoutEntriesIterator struct {
+	EntriesIterator
+	ctx    context.Context
+	cancel context.CancelFunc
+	store  *TimeoutStore
+}
+
+// timeoutKeysIterator releases the operation context when closed
+type timeoutKeysIterator struct {
+	KeysIterator
+	ctx    context.Context
+	cancel context.CancelFunc
+	store  *TimeoutStore
+}
+
+func NewTimeoutStore(store Store, timeout time.Duration) *TimeoutStore {
+	return &TimeoutStore{
+		Store:   store,
+		Timeout: timeout,
+	}
+}
+
+func (s *TimeoutStore) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, s.Timeout)
+}
+
+// wrapErr marks errors caused by the operation deadline. Not found and predicate errors are returned unchanged.
+func (s *TimeoutStore) wrapErr(ctx context.Context, err error) error {
+	if err == nil || errors.Is(err, ErrNotFound) || errors.Is(err, ErrPredicateFailed) {
+		return err
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) && !errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("operation timeout %s: %s: %w", s.Timeout, err, context.DeadlineExceeded)
+	}
+	return err
+}
+
+func (s *TimeoutStore) Get(ctx context.Context, key []byte) ([]byte, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	value, err := s.Store.Get(ctx, key)
+	return value, s.wrapErr(ctx, err)
+}
+
+func (s *TimeoutStore) Set(ctx context.Context, key, value []byte) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	return s.wrapErr(ctx, s.Store.Set(ctx, key, value))
+}
+
+func (s *TimeoutStore) SetIf(ctx context.Context, key, value, valuePredicate []byte) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	return s.wrapErr(ctx, s.Store.SetIf(ctx, key, value, valuePredicate))
+}
+
+func (s *TimeoutStore) CompareAndSwapMany(ctx context.Context, ops []CASOp) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	return s.wrapErr(ctx, s.Store.CompareAndSwapMany(ctx, ops))
+}
+
+func (s *TimeoutStore) SetIfFunc(ctx context.Context, key, value []byte, pred func(current []byte) bool) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	return s.wrapErr(ctx, s.Store.SetIfFunc(ctx, key, value, pred))
+}
+
+func (s *TimeoutStore) GetOrSet(ctx context.Context, key, defaultValue []byte) ([]byte, bool, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	value, created, err := s.Store.GetOrSet(ctx, key, defaultValue)
+	return value, created, s.wrapErr(ctx, err)
+}
+
+func (s *TimeoutStore) Delete(ctx context.Context, key []byte) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	return s.wrapErr(ctx, s.Store.Delete(ctx, key))
+}
+
+func (s *TimeoutStore) DeleteIf(ctx context.Context, key, valuePredicate []byte) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	return s.wrapErr(ctx, s.Store.DeleteIf(ctx, key, valuePredicate))
+}
+
+func (s *TimeoutStore) DeleteBatch(ctx context.Context, keys [][]byte) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	return s.wrapErr(ctx, s.Store.DeleteBatch(ctx, keys))
+}
+
+func (s *TimeoutStore) DeletePrefix(ctx context.Context, prefix []byte, deleteAll bool) (int64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	deleted, err := s.Store.DeletePrefix(ctx, prefix, deleteAll)
+	return deleted, s.wrapErr(ctx, err)
+}
+
+func (s *TimeoutStore) Rename(ctx context.Context, oldKey, newKey []byte, overwrite bool) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	return s.wrapErr(ctx, s.Store.Rename(ctx, oldKey, newKey, overwrite))
+}
+
+func (s *TimeoutStore) RenamePrefix(ctx context.Context, oldPrefix, newPrefix []byte, overwrite bool) (int64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	renamed, err := s.Store.RenamePrefix(ctx, oldPrefix, newPrefix, overwrite)
+	return renamed, s.wrapErr(ctx, err)
+}
+
+func (s *TimeoutStore) Scan(ctx context.Context, start []byte) (EntriesIterator, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	it, err := s.Store.Scan(ctx, start)
+	if err != nil {
+		cancel()
+		return nil, s.wrapErr(ctx, err)
+	}
+	return &timeoutEntriesIterator{EntriesIterator: it, ctx: ctx, cancel: cancel, store: s}, nil
+}
+
+func (s *TimeoutStore) ScanSnapshot(ctx context.Context, start []byte) (EntriesIterator, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	it, err := s.Store.ScanSnapshot(ctx, start)
+	if err != nil {
+		cancel()
+		return nil, s.wrapErr(ctx, err)
+	}
+	return &timeoutEntriesIterator{EntriesIterator: it, ctx: ctx, cancel: cancel, store: s}, nil
+}
+
+func (s *TimeoutStore) GetRange(ctx context.Context, prefix []byte, limit int) (map[string][]byte, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	res, err := s.Store.GetRange(ctx, prefix, limit)
+	return res, s.wrapErr(ctx, err)
+}
+
+func (s *TimeoutStore) ListKeys(ctx context.Context, prefix []byte) (KeysIterator, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	it, err := s.Store.ListKeys(ctx, prefix)
+	if err != nil {
+		cancel()
+		return nil, s.wrapErr(ctx, err)
+	}
+	return &timeoutKeysIterator{KeysIterator: it, ctx: ctx, cancel: cancel, store: s}, nil
+}
+
+func (s *TimeoutStore) Flush(ctx context.Context) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	return s.wrapErr(ctx, s.Store.Flush(ctx))
+}
+
+func (s *TimeoutStore) Clone() Store {
+	return NewTimeoutStore(s.Store.Clone(), s.Timeout)
+}
+
+// PoolStats returns the stats of the wrapped store connection pool
+func (s *TimeoutStore) PoolStats() (PoolStatsSnapshot, error) {
+	return GetPoolStats(s.Store)
+}
+
+// Compact compacts the wrapped store, when supported
+func (s *TimeoutStore) Compact(ctx context.Context) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	return s.wrapErr(ctx, Compact(ctx, s.Store))
+}
+
+func (s *TimeoutStore) Close() {
+	s.Store.Close()
+}
+
+func (it *timeoutEntriesIterator) Err() error {
+	return it.store.wrapErr(it.ctx, it.EntriesIterator.Err())
+}
+
+func (it *timeoutEntriesIterator) Close() {
+	it.EntriesIterator.Close()
+	it.cancel()
+}
+
+func (it *timeoutKeysIterator) Err() error {
+	return it.store.wrapErr(it.ctx, it.KeysIterator.Err())
+}
+
+func (it *timeoutKeysIterator) Close() {
+	it.KeysIterator.Close()
+	it.cancel()
+}
@@ -0,0 +1,99 @@
+package kv_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/treeverse/lakefs/pkg/kv"
+	"github.com/treeverse/lakefs/pkg/kv/mem"
+)
+
+// slowStore delays Get by delay, or until the context is done
+type slowStore struct {
+	kv.Store
+	delay    time.Duration
+	deadline time.Time
+}
+
+func (s *slowStore) Get(ctx context.Context, key []byte) ([]byte, error) {
+	s.deadline, _ = ctx.Deadline()
+	select {
+	case <-time.After(s.delay):
+		return s.Store.Get(ctx, key)
+	case <-ctx.Done():
+		return nil, fmt.Errorf("%s: %w", ctx.Err(), kv.ErrOperationFailed)
+	}
+}
+
+func newSlowStore(t *testing.T, delay time.Duration) *slowStore {
+	t.Helper()
+	store, err := (&mem.Driver{}).Open(context.Background(), "")
+	if err != nil {
+		t.Fatalf("failed to open store: %s", err)
+	}
+	t.Cleanup(store.Close)
+	return &slowStore{Store: store, delay: delay}
+}
+
+func TestTimeoutStore(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("timeout", func(t *testing.T) {
+		store := kv.NewTimeoutStore(newSlowStore(t, time.Minute), 50*time.Millisecond)
+		start := time.Now()
+		_, err := store.Get(ctx, []byte("key"))
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("Get err=%v, expected %s", err, context.DeadlineExceeded)
+		}
+		if elapsed := time.Since(start); elapsed > 10*time.Second {
+			t.Fatalf("Get took %s, expected to be bounded by the store timeout", elapsed)
+		}
+	})
+
+	t.Run("caller_deadline", func(t *testing.T) {
+		slow := newSlowStore(t, time.Minute)
+		store := kv.NewTimeoutStore(slow, time.Hour)
+		callerCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+		callerDeadline, _ := callerCtx.Deadline()
+		_, err := store.Get(callerCtx, []byte("key"))
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("Get err=%v, expected %s", err, context.DeadlineExceeded)
+		}
+		if !slow.deadline.Equal(callerDeadline) {
+			t.Fatalf("operation deadline %s, expected the shorter caller deadline %s", slow.deadline, callerDeadline)
+		}
+	})
+
+	t.Run("forward_errors", func(t *testing.T) {
+		store := kv.NewTimeoutStore(newSlowStore(t, 0), time.Minute)
+		if _, err := store.Get(ctx, []byte("missing")); err != kv.ErrNotFound {
+			t.Fatalf("Get missing key err=%v, expected %s", err, kv.ErrNotFound)
+		}
+		key := []byte("exists")
+		if err := store.Set(ctx, key, []byte("v")); err != nil {
+			t.Fatalf("Set: %s", err)
+		}
+		if err := store.SetIf(ctx, key, []byte("v2"), nil); err != kv.ErrPredicateFailed {
+			t.Fatalf("SetIf err=%v, expected %s", err, kv.ErrPredicateFailed)
+		}
+	})
+
+	t.Run("scan", func(t *testing.T) {
+		store := kv.NewTimeoutStore(newSlowStore(t, 0), time.Minute)
+		if err := store.Set(ctx, []byte("scan-key"), []byte("v")); err != nil {
+			t.Fatalf("Set: %s", err)
+		}
+		it, err := store.Scan(ctx, []byte("scan-"))
+		if err != nil {
+			t.Fatalf("Scan: %s", err)
+		}
+		defer it.Close()
+		if !it.Next() || string(it.Entry().Key) != "scan-key" {
+			t.Fatalf("Scan expected to read 'scan-key' (err=%v)", it.Err())
+		}
+	})
+}